@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/itsmostafa/goralph/internal/loop"
+	"github.com/spf13/cobra"
+)
+
+var doctorProfile string
+var doctorTasks bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment the loop depends on",
+	Long: `Check agent binaries and versions, git repository state, branch and remote,
+writability of .ralph/, the prompt file and the verification commands.
+
+Each check reports pass, warn or fail. goralph run performs the same checks
+(without agent versions) before starting and refuses to start if any fail.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loop.LoadConfig(doctorProfile)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("tasks") {
+			cfg.Tasks = doctorTasks
+		}
+
+		checks := loop.RunDiagnostics(cfg, true)
+		loop.FormatDiagnostics(cmd.OutOrStdout(), checks)
+		if loop.HasFailures(checks) {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("some checks failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorProfile, "profile", "", "Named profile from the config file to check against")
+	doctorCmd.Flags().BoolVar(&doctorTasks, "tasks", false, "Check the task queue in .ralph/tasks/ instead of the prompt file")
+
+	rootCmd.AddCommand(doctorCmd)
+}
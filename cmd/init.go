@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/itsmostafa/goralph/internal/loop"
+	"github.com/spf13/cobra"
+)
+
+var initTemplate string
+var initForce bool
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Scaffold .ralph/ in the current project",
+	Long: fmt.Sprintf(`Create .ralph/PROMPT.md from a template, a starter .ralph/config.yaml and
+a .ralph/.gitignore for logs, state and session records.
+
+Verification commands are pre-filled from the detected project type
+(Go, Node.js, Rust, Python or Makefile).
+
+Templates: %s`, strings.Join(loop.TemplateNames(), ", ")),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loop.InitProject(cmd.OutOrStdout(), loop.InitOptions{
+			Template: initTemplate,
+			Force:    initForce,
+		})
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initTemplate, "template", "t", "feature", "Prompt template ("+strings.Join(loop.TemplateNames(), ", ")+")")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite existing files")
+
+	rootCmd.AddCommand(initCmd)
+}
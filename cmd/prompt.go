@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itsmostafa/goralph/internal/loop"
+	"github.com/spf13/cobra"
+)
+
+var promptIteration int
+var promptOutput string
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Render the prompt the agent would receive",
+	Long: `Build the prompt for an iteration through the active mode exactly as goralph run
+would send it to the agent, without invoking the agent.
+
+The plan and RLM state are initialized in a throwaway directory, so nothing in
+.ralph/ is modified. The prompt is printed to stdout (or written to --output) and
+its size and approximate token count are reported on stderr.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loop.LoadConfig(profile)
+		if err != nil {
+			return err
+		}
+		overlay, err := flagOverlay(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		if err := overlay.Apply(&cfg); err != nil {
+			return err
+		}
+		return renderPrompt(cmd, cfg, promptIteration, promptOutput)
+	},
+}
+
+// renderPrompt renders the prompt for an iteration to stdout or a file and reports its size on stderr
+func renderPrompt(cmd *cobra.Command, cfg loop.Config, iteration int, output string) error {
+	prompt, err := loop.RenderPrompt(cfg, iteration)
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		if err := os.WriteFile(output, prompt, 0644); err != nil {
+			return fmt.Errorf("failed to write prompt: %w", err)
+		}
+	} else {
+		cmd.OutOrStdout().Write(prompt)
+		fmt.Fprintln(cmd.OutOrStdout())
+	}
+
+	if cfg.Mode == "" {
+		cfg.Mode = loop.ModeRalph
+	}
+	loop.FormatPromptStats(cmd.ErrOrStderr(), cfg.Mode, max(iteration, 1), prompt)
+	return nil
+}
+
+func init() {
+	promptCmd.Flags().IntVar(&promptIteration, "iteration", 1, "Iteration number to render the prompt for")
+	promptCmd.Flags().StringVarP(&promptOutput, "output", "o", "", "Write the prompt to this file instead of stdout")
+
+	// Settings that change the rendered prompt, shared with goralph run
+	promptCmd.Flags().IntVarP(&maxIterations, "max", "n", 0, "Maximum number of iterations (0 = unlimited)")
+	promptCmd.Flags().BoolVar(&noPush, "no-push", false, "Render the prompt for a session that doesn't push")
+	promptCmd.Flags().BoolVar(&autoCommit, "auto-commit", false, "Render the prompt for a session where goralph commits")
+	promptCmd.Flags().StringVar(&mode, "mode", "ralph", "Execution mode (ralph, rlm)")
+	promptCmd.Flags().IntVar(&maxDepth, "max-depth", 3, "Maximum recursion depth for RLM mode")
+	promptCmd.Flags().StringVar(&profile, "profile", "", "Named profile from the config file (e.g. overnight, dry)")
+	promptCmd.Flags().BoolVar(&tasks, "tasks", false, "Render the pending task from .ralph/tasks/")
+	promptCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable for the prompt file as key=value (repeatable)")
+
+	rootCmd.AddCommand(promptCmd)
+}
@@ -1,67 +1,242 @@
 package cmd
 
 import (
-	"os"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/itsmostafa/goralph/internal/loop"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var maxIterations int
 var noPush bool
+var autoCommit bool
+var branchName string
+var allowProtected bool
+var worktree bool
+var worktreeCleanup string
 var agent string
+var model string
 var mode string
 var verifyEnabled bool
+var verifyCommands []string
 var maxDepth int
+var profile string
+var retryAttempts int
+var retryBackoff time.Duration
+var retryBackoffMax time.Duration
+var iterationTimeout time.Duration
+var idleTimeout time.Duration
+var onTimeout string
+var onVerifyFail string
+var verifyOutputLimit int
+var maxCost float64
+var maxTokens int
+var budgetWarn int
+var resume string
+var force bool
+var dryRun bool
+var dryRunIteration int
+var templateVars []string
+var tasks bool
+var taskMax int
 
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run the agentic loop",
-	Long:  `Run the agentic loop using .ralph/PROMPT.md as the prompt file.`,
+	Long: `Run the agentic loop using .ralph/PROMPT.md as the prompt file.
+
+Settings are resolved in order of increasing precedence: built-in defaults,
+~/.config/goralph/config.yaml, .ralph/config.yaml, the --profile overlay,
+GORALPH_* environment variables, then command-line flags.
+
+Built-in profiles: overnight (unlimited, rlm, verify, push) and dry
+(3 iterations, no push). Profiles can be added or overridden under the
+"profiles" key of a config file.
+
+Use --resume to continue the latest session, or --resume <session-id> for a
+specific one, keeping its plan or RLM state and iteration counter.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Validate agent provider
-		agentProvider, err := loop.ValidateAgentProvider(agent)
+		// "--resume <id>" leaves the ID as a positional argument, since the flag's value is optional
+		if len(args) > 0 {
+			if !cmd.Flags().Changed("resume") || resume != "latest" {
+				return fmt.Errorf("unexpected argument: %s", args[0])
+			}
+			resume = args[0]
+		}
+		if force && resume == "" {
+			return fmt.Errorf("--force can only be used with --resume")
+		}
+
+		// Resolve config files, the selected profile and environment variables
+		cfg, err := loop.LoadConfig(profile)
 		if err != nil {
 			return err
 		}
 
-		// Validate mode
-		validatedMode, err := loop.ValidateMode(mode)
+		// Flags take precedence over everything else
+		overlay, err := flagOverlay(cmd.Flags())
 		if err != nil {
 			return err
 		}
+		if err := overlay.Apply(&cfg); err != nil {
+			return err
+		}
+
+		cfg.PlanFile = loop.GeneratePlanPath()
+		cfg.Output = cmd.OutOrStdout()
+
+		if dryRun {
+			return renderPrompt(cmd, cfg, dryRunIteration, "")
+		}
 
-		return loop.Run(loop.Config{
-			PromptFile:    loop.PromptFile,
-			PlanFile:      loop.GeneratePlanPath(),
-			MaxIterations: maxIterations,
-			NoPush:        noPush,
-			Agent:         agentProvider,
-			Output:        cmd.OutOrStdout(),
-			Mode:          validatedMode,
-			RLMMaxDepth:   maxDepth,
-			VerifyEnabled: verifyEnabled,
-		})
+		cfg.Resume = resume
+		cfg.Force = force
+
+		// Errors from here on are about the session, not the command line
+		cmd.SilenceUsage = true
+		return loop.Run(cfg)
 	},
 }
 
+// flagOverlay builds a config overlay from the flags explicitly set on the command line
+func flagOverlay(flags *pflag.FlagSet) (loop.ConfigOverlay, error) {
+	var o loop.ConfigOverlay
+	if flags.Changed("max") {
+		o.MaxIterations = &maxIterations
+	}
+	if flags.Changed("no-push") {
+		o.NoPush = &noPush
+	}
+	if flags.Changed("auto-commit") {
+		o.AutoCommit = &autoCommit
+	}
+	if flags.Changed("branch") {
+		o.Branch = &branchName
+	}
+	if flags.Changed("allow-protected") {
+		o.AllowProtected = &allowProtected
+	}
+	if flags.Changed("worktree") {
+		o.Worktree = &worktree
+	}
+	if flags.Changed("worktree-cleanup") {
+		o.WorktreeCleanup = &worktreeCleanup
+	}
+	if flags.Changed("agent") {
+		o.Agent = &agent
+	}
+	if flags.Changed("model") {
+		o.Model = &model
+	}
+	if flags.Changed("mode") {
+		o.Mode = &mode
+	}
+	if flags.Changed("verify") {
+		o.VerifyEnabled = &verifyEnabled
+	}
+	if flags.Changed("verify-cmd") {
+		o.VerifyCommands = verifyCommands
+	}
+	if flags.Changed("max-depth") {
+		o.RLMMaxDepth = &maxDepth
+	}
+	if flags.Changed("iteration-timeout") {
+		o.IterationTimeout = &iterationTimeout
+	}
+	if flags.Changed("idle-timeout") {
+		o.IdleTimeout = &idleTimeout
+	}
+	if flags.Changed("on-timeout") {
+		o.OnTimeout = &onTimeout
+	}
+	if flags.Changed("on-verify-fail") {
+		o.OnVerifyFail = &onVerifyFail
+	}
+	if flags.Changed("verify-output-limit") {
+		o.VerifyOutputLimit = &verifyOutputLimit
+	}
+	if flags.Changed("max-cost") {
+		o.MaxCost = &maxCost
+	}
+	if flags.Changed("max-tokens") {
+		o.MaxTokens = &maxTokens
+	}
+	if flags.Changed("budget-warn") {
+		o.BudgetWarnPercent = &budgetWarn
+	}
+	if flags.Changed("retries") {
+		o.Retry.MaxAttempts = &retryAttempts
+	}
+	if flags.Changed("retry-backoff") {
+		o.Retry.BackoffBase = &retryBackoff
+	}
+	if flags.Changed("retry-backoff-max") {
+		o.Retry.BackoffCap = &retryBackoffMax
+	}
+	if flags.Changed("tasks") {
+		o.Tasks = &tasks
+	}
+	if flags.Changed("task-max") {
+		o.TaskMaxIterations = &taskMax
+	}
+	if flags.Changed("var") {
+		o.Vars = make(map[string]string, len(templateVars))
+		for _, v := range templateVars {
+			key, value, ok := strings.Cut(v, "=")
+			if !ok || key == "" {
+				return o, fmt.Errorf("invalid --var %q: expected key=value", v)
+			}
+			o.Vars[key] = value
+		}
+	}
+	return o, nil
+}
+
 func init() {
 	runCmd.Flags().IntVarP(&maxIterations, "max", "n", 0, "Maximum number of iterations (0 = unlimited)")
 	runCmd.Flags().BoolVar(&noPush, "no-push", false, "Skip committing and pushing changes after each iteration")
+	runCmd.Flags().StringVar(&branchName, "branch", "", "Branch to work on: auto (ralph/<session-id>) or a name, created from HEAD if missing")
+	runCmd.Flags().BoolVar(&allowProtected, "allow-protected", false, "Run even on a protected branch such as main")
+	runCmd.Flags().BoolVar(&worktree, "worktree", false, "Run the agent in a git worktree under .ralph/worktrees/<session-id> on its own branch")
+	runCmd.Flags().StringVar(&worktreeCleanup, "worktree-cleanup", loop.WorktreeKeep, "What to do with the worktree when the session exits: keep, remove or on-complete")
+	runCmd.Flags().BoolVar(&autoCommit, "auto-commit", false, "Stage and commit the agent's changes after each iteration instead of relying on the agent")
 
-	// Agent provider flag with env var fallback
-	defaultAgent := "claude"
-	if envAgent := os.Getenv("GORALPH_AGENT"); envAgent != "" {
-		defaultAgent = envAgent
-	}
-	runCmd.Flags().StringVar(&agent, "agent", defaultAgent, "Agent provider to use (claude, codex)")
+	// Agent provider flag (GORALPH_AGENT and config files are resolved by loop.LoadConfig)
+	runCmd.Flags().StringVar(&agent, "agent", "claude", "Agent provider to use (claude, codex, gemini, command); a comma-separated list falls back in order")
+
+	runCmd.Flags().StringVar(&model, "model", "", "Model passed to the agent CLI (default: agent's own default)")
 
 	// Mode flag
 	runCmd.Flags().StringVar(&mode, "mode", "ralph", "Execution mode (ralph, rlm)")
 
 	// Other flags
 	runCmd.Flags().BoolVar(&verifyEnabled, "verify", false, "Run verification (build/test) before commit")
+	runCmd.Flags().StringArrayVar(&verifyCommands, "verify-cmd", nil, "Verification command to run (repeatable, auto-detected if unset)")
 	runCmd.Flags().IntVar(&maxDepth, "max-depth", 3, "Maximum recursion depth for RLM mode")
+	runCmd.Flags().DurationVar(&iterationTimeout, "iteration-timeout", 0, "Kill the agent if an iteration runs longer than this (0 = no limit)")
+	runCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "Kill the agent if it produces no output for this long (0 = no limit)")
+	runCmd.Flags().StringVar(&onTimeout, "on-timeout", "continue", "What to do after a timeout (continue, stop)")
+	runCmd.Flags().StringVar(&onVerifyFail, "on-verify-fail", "keep", "What to do with an iteration's changes when verification fails (keep, revert, fixup)")
+	runCmd.Flags().IntVar(&verifyOutputLimit, "verify-output-limit", 4000, "Bytes of each failed check's output, from the end, shown in the next prompt (0 = all)")
+	runCmd.Flags().Float64Var(&maxCost, "max-cost", 0, "Stop the session once total cost reaches this many USD (0 = unlimited)")
+	runCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Stop the session once total input+output tokens reach this limit (0 = unlimited)")
+	runCmd.Flags().IntVar(&budgetWarn, "budget-warn", 80, "Warn once usage reaches this percentage of a budget (0 = never)")
+	runCmd.Flags().IntVar(&retryAttempts, "retries", 1, "Maximum attempts for a failed iteration or push (1 = no retries)")
+	runCmd.Flags().DurationVar(&retryBackoff, "retry-backoff", 10*time.Second, "Delay before the first retry, doubled for each subsequent retry")
+	runCmd.Flags().DurationVar(&retryBackoffMax, "retry-backoff-max", 5*time.Minute, "Maximum delay between retries (0 = no cap)")
+	runCmd.Flags().StringVar(&profile, "profile", "", "Named profile from the config file (e.g. overnight, dry)")
+	runCmd.Flags().BoolVar(&tasks, "tasks", false, "Work through the prompt files in .ralph/tasks/ in order")
+	runCmd.Flags().IntVar(&taskMax, "task-max", 0, "Iterations per task before moving to the next (0 = unlimited)")
+	runCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable for the prompt file as key=value (repeatable)")
+	runCmd.Flags().StringVar(&resume, "resume", "", "Resume a previous session by ID, or the latest session if no ID is given")
+	runCmd.Flags().Lookup("resume").NoOptDefVal = "latest"
+	runCmd.Flags().BoolVar(&force, "force", false, "Resume even if the prompt file changed since the session started")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the prompt the agent would receive instead of running it")
+	runCmd.Flags().IntVar(&dryRunIteration, "iteration", 1, "Iteration to render the prompt for with --dry-run")
 
 	rootCmd.AddCommand(runCmd)
 }
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/itsmostafa/goralph/internal/loop"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List and inspect past sessions",
+	Long: `List and inspect the sessions recorded in .ralph/sessions/.
+
+Session IDs can be given as "latest" to refer to the most recent session.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded sessions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := loop.ListSessions()
+		if err != nil {
+			return err
+		}
+		loop.FormatSessionList(cmd.OutOrStdout(), sessions)
+		return nil
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Show a session's details, iterations, logs and plan",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := loop.ResolveSessionID(args[0])
+		if err != nil {
+			return err
+		}
+		return loop.ShowSession(cmd.OutOrStdout(), id)
+	},
+}
+
+var sessionsRmCmd = &cobra.Command{
+	Use:   "rm <session-id>",
+	Short: "Remove a session with its plan and logs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := loop.ResolveSessionID(args[0])
+		if err != nil {
+			return err
+		}
+		if err := loop.RemoveSession(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed session %s\n", id)
+		return nil
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsRmCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
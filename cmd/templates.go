@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/itsmostafa/goralph/internal/loop"
+	"github.com/spf13/cobra"
+)
+
+var templatesForce bool
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Manage the prompt blocks that wrap PROMPT.md",
+	Long: `The system context, plan instructions and RLM phase guidance that wrap
+PROMPT.md are built from prompt blocks. Each block can be overridden by a Go
+text/template at .ralph/templates/<mode>/<block>.md; blocks without an override
+use the built-in defaults.`,
+}
+
+var templatesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the built-in prompt blocks to .ralph/templates/ for editing",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths, err := loop.ExportTemplates(templatesForce)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	templatesExportCmd.Flags().BoolVar(&templatesForce, "force", false, "Overwrite existing templates")
+
+	templatesCmd.AddCommand(templatesExportCmd)
+	rootCmd.AddCommand(templatesCmd)
+}
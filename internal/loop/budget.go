@@ -0,0 +1,76 @@
+package loop
+
+import "fmt"
+
+// Budget accumulates cost and token usage across iterations and checks it against
+// the session limits in Config
+type Budget struct {
+	maxCost     float64
+	maxTokens   int
+	warnPercent int
+
+	Cost   float64 // Total cost in USD of iterations whose provider reported (or estimated) cost
+	Tokens int     // Total input and output tokens
+
+	warned bool
+}
+
+// NewBudget creates a Budget from the session limits in cfg
+func NewBudget(cfg Config) *Budget {
+	return &Budget{
+		maxCost:     cfg.MaxCost,
+		maxTokens:   cfg.MaxTokens,
+		warnPercent: cfg.BudgetWarnPercent,
+	}
+}
+
+// Add records the usage of an iteration's result
+func (b *Budget) Add(result *ResultMessage) {
+	if result == nil {
+		return
+	}
+	if result.HasCost {
+		b.Cost += result.TotalCostUSD
+	}
+	b.Tokens += result.Usage.InputTokens + result.Usage.OutputTokens
+}
+
+// AddRecorded counts the usage of iterations already recorded by earlier runs of a
+// resumed session, so resuming doesn't reset the limits
+func (b *Budget) AddRecorded(records []IterationRecord) {
+	for _, record := range records {
+		b.Cost += record.CostUSD
+		b.Tokens += record.InputTokens + record.OutputTokens
+	}
+}
+
+// Exceeded returns a description of the first limit that has been reached, or "" if none
+func (b *Budget) Exceeded() string {
+	if b.maxCost > 0 && b.Cost >= b.maxCost {
+		return fmt.Sprintf("cost $%.4f reached limit of $%.4f", b.Cost, b.maxCost)
+	}
+	if b.maxTokens > 0 && b.Tokens >= b.maxTokens {
+		return fmt.Sprintf("%s tokens reached limit of %s", formatNumber(b.Tokens), formatNumber(b.maxTokens))
+	}
+	return ""
+}
+
+// Warning returns a description of usage once it first crosses the warning percentage
+// of any limit, and "" otherwise. It only warns once per session.
+func (b *Budget) Warning() string {
+	if b.warned || b.warnPercent <= 0 {
+		return ""
+	}
+
+	threshold := float64(b.warnPercent) / 100
+	var msg string
+	if b.maxCost > 0 && b.Cost >= b.maxCost*threshold {
+		msg = fmt.Sprintf("Budget warning: cost $%.4f is %.0f%% of $%.4f limit", b.Cost, b.Cost/b.maxCost*100, b.maxCost)
+	} else if b.maxTokens > 0 && float64(b.Tokens) >= float64(b.maxTokens)*threshold {
+		msg = fmt.Sprintf("Budget warning: %s tokens is %.0f%% of %s limit", formatNumber(b.Tokens), float64(b.Tokens)/float64(b.maxTokens)*100, formatNumber(b.maxTokens))
+	}
+	if msg != "" {
+		b.warned = true
+	}
+	return msg
+}
@@ -0,0 +1,181 @@
+package loop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSubjectLength caps the subject line of goralph-owned commits
+const maxSubjectLength = 72
+
+// RejectedDir holds the changes of iterations reverted after failing verification
+const RejectedDir = ".ralph/rejected"
+
+// localStatePaths are goralph's machine-local files, which goralph-owned commits never include
+var localStatePaths = []string{".ralph/logs", ".ralph/state", ".ralph/sessions", WorktreesDir, RejectedDir, QueueFile}
+
+// projectPathspecs matches every path in the project in dir except goralph's local
+// state. Paths that are already gitignored (as with the .gitignore goralph init writes)
+// are left out, since git add refuses pathspecs naming ignored paths.
+func projectPathspecs(dir string) []string {
+	ignored := ignoredPaths(dir, localStatePaths...)
+	specs := []string{"."}
+	for _, path := range localStatePaths {
+		if !ignored[path] {
+			specs = append(specs, ":(exclude)"+path)
+		}
+	}
+	return specs
+}
+
+// commitIteration stages and commits everything the agent changed in an iteration,
+// with a message derived from the plan task it completed or its final result text.
+// It returns the new commit hash, or "" if there was nothing to commit.
+func commitIteration(cfg Config, iteration int, result *ResultMessage, planBefore string) (string, error) {
+	if err := stageChanges(cfg.WorkDir, projectPathspecs(cfg.WorkDir)...); err != nil {
+		return "", fmt.Errorf("failed to stage changes: %w", err)
+	}
+	staged, err := hasStagedChanges(cfg.WorkDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to check staged changes: %w", err)
+	}
+	if !staged {
+		return "", nil
+	}
+
+	planAfter, _ := os.ReadFile(planPath(cfg))
+	message := commitMessage(cfg, iteration, result, completedTasks(planBefore, string(planAfter)))
+	if err := commitStaged(cfg.WorkDir, message); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %w", err)
+	}
+	return getHeadCommit(cfg.WorkDir)
+}
+
+// revertIteration undoes everything an iteration changed, committed or not: the branch
+// goes back to startHead and the working tree back to the snapshot taken before the
+// agent ran, so changes that were already uncommitted survive. The discarded changes
+// are saved to .ralph/rejected/<session-id>/<iteration>.patch, whose path is returned.
+func revertIteration(cfg Config, iteration int, startHead, snapshot string) (string, error) {
+	if startHead == "" || snapshot == "" {
+		return "", fmt.Errorf("no pre-iteration snapshot to revert to")
+	}
+	dir := filepath.Join(RejectedDir, cfg.SessionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create rejected directory: %w", err)
+	}
+
+	patch, err := restoreSnapshot(cfg.WorkDir, startHead, snapshot, projectPathspecs(cfg.WorkDir)...)
+	// Save whatever was captured, even if restoring failed part way
+	path := filepath.Join(dir, fmt.Sprintf("%d.patch", iteration))
+	if len(patch) > 0 {
+		if err := os.WriteFile(path, patch, 0644); err != nil {
+			return "", fmt.Errorf("failed to write rejected patch: %w", err)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to reset to %s: %w", shortHash(startHead), err)
+	}
+	return path, nil
+}
+
+// commitMessage builds a commit message: a subject from the first completed plan task,
+// falling back to the agent's result text, followed by goralph trailers
+func commitMessage(cfg Config, iteration int, result *ResultMessage, completed []string) string {
+	subject := ""
+	if len(completed) > 0 {
+		subject = completed[0]
+	} else if result != nil {
+		subject = summaryLine(result.Result)
+	}
+	if subject == "" {
+		subject = fmt.Sprintf("goralph iteration %d", iteration)
+	}
+	subject = truncateSubject(subject)
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	if len(completed) > 1 {
+		b.WriteString("\nCompleted tasks:\n")
+		for _, task := range completed {
+			b.WriteString("- " + task + "\n")
+		}
+	}
+
+	agent, model := string(cfg.Agent), cfg.Model
+	if result != nil {
+		if result.Agent != "" {
+			agent = result.Agent
+		}
+		if result.Model != "" {
+			model = result.Model
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString("Goralph-Session: " + cfg.SessionID + "\n")
+	b.WriteString(fmt.Sprintf("Goralph-Iteration: %d\n", iteration))
+	b.WriteString("Goralph-Agent: " + agent + "\n")
+	if model != "" {
+		b.WriteString("Goralph-Model: " + model + "\n")
+	}
+	if result != nil && result.HasCost {
+		b.WriteString("Goralph-Cost: " + formatCost(result.TotalCostUSD, result.CostEstimated) + "\n")
+	}
+	return b.String()
+}
+
+// completedTasks returns the "- [x]" plan tasks in after that weren't completed in before
+func completedTasks(before, after string) []string {
+	done := make(map[string]bool)
+	for _, task := range checkedTasks(before) {
+		done[task] = true
+	}
+	var tasks []string
+	for _, task := range checkedTasks(after) {
+		if !done[task] {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// checkedTasks returns the descriptions of the checked-off tasks in a plan
+func checkedTasks(plan string) []string {
+	var tasks []string
+	for _, line := range strings.Split(plan, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"- [x] ", "- [X] "} {
+			if task, ok := strings.CutPrefix(line, prefix); ok && strings.TrimSpace(task) != "" {
+				tasks = append(tasks, strings.TrimSpace(task))
+			}
+		}
+	}
+	return tasks
+}
+
+// summaryLine returns the first meaningful line of an agent's result text,
+// skipping headings and completion markers and stripping markdown decoration
+func summaryLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.Contains(line, CompletionPromise) || strings.HasPrefix(line, "<rlm:") {
+			continue
+		}
+		line = strings.NewReplacer("**", "", "__", "", "`", "").Replace(line)
+		line = strings.TrimSpace(strings.TrimLeft(line, "*-> "))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// truncateSubject shortens a subject line to maxSubjectLength runes
+func truncateSubject(subject string) string {
+	runes := []rune(subject)
+	if len(runes) <= maxSubjectLength {
+		return subject
+	}
+	return strings.TrimSpace(string(runes[:maxSubjectLength-3])) + "..."
+}
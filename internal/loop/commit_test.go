@@ -0,0 +1,158 @@
+package loop
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initTestProject creates a git repository in a temporary directory, scaffolds it
+// with InitProject and commits the result. The test runs inside the repository.
+func initTestProject(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "goralph test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	testGit(t, "init", "-q", "-b", "main")
+	if err := InitProject(io.Discard, InitOptions{}); err != nil {
+		t.Fatalf("InitProject: %v", err)
+	}
+	testGit(t, "add", "-A")
+	testGit(t, "commit", "-q", "-m", "Initial commit")
+}
+
+// testGit runs a git command in the current directory and returns its trimmed output
+func testGit(t *testing.T, args ...string) string {
+	t.Helper()
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeTestFile writes a file, creating its directory
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitIterationInScaffoldedProject(t *testing.T) {
+	initTestProject(t)
+
+	// Local state that .ralph/.gitignore ignores, next to the agent's work
+	writeTestFile(t, ".ralph/logs/iter1.jsonl", "{}\n")
+	writeTestFile(t, ".ralph/sessions/s1/session.json", "{}\n")
+	writeTestFile(t, QueueFile, "{}\n")
+	writeTestFile(t, "main.go", "package main\n")
+
+	cfg := DefaultConfig()
+	cfg.SessionID = "s1"
+	cfg.PlanFile = ".ralph/plans/implementation_plan_s1.md"
+	hash, err := commitIteration(cfg, 1, &ResultMessage{Result: "Add the entry point"}, "")
+	if err != nil {
+		t.Fatalf("commitIteration: %v", err)
+	}
+	if hash == "" {
+		t.Fatal("expected a commit")
+	}
+
+	files := testGit(t, "show", "--name-only", "--format=", hash)
+	if files != "main.go" {
+		t.Errorf("committed files = %q, want only main.go", files)
+	}
+	if subject := testGit(t, "log", "-1", "--format=%s"); subject != "Add the entry point" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	// Nothing left to commit
+	hash, err = commitIteration(cfg, 2, nil, "")
+	if err != nil {
+		t.Fatalf("commitIteration with no changes: %v", err)
+	}
+	if hash != "" {
+		t.Errorf("expected no commit, got %s", hash)
+	}
+}
+
+func TestRevertIterationInScaffoldedProject(t *testing.T) {
+	initTestProject(t)
+	writeTestFile(t, "tracked.txt", "original\n")
+	testGit(t, "add", "tracked.txt")
+	testGit(t, "commit", "-q", "-m", "Add tracked file")
+
+	// Uncommitted work from before the iteration, plus ignored local state
+	writeTestFile(t, "tracked.txt", "edited before the iteration\n")
+	writeTestFile(t, "notes.txt", "untracked before the iteration\n")
+	writeTestFile(t, ".ralph/logs/iter1.jsonl", "{}\n")
+
+	startHead, err := getHeadCommit("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := snapshotTree("", projectPathspecs("")...)
+	if err != nil {
+		t.Fatalf("snapshotTree: %v", err)
+	}
+
+	// The iteration commits one change and leaves another uncommitted
+	writeTestFile(t, "committed.txt", "agent commit\n")
+	testGit(t, "add", "committed.txt")
+	testGit(t, "commit", "-q", "-m", "Agent commit")
+	writeTestFile(t, "added.txt", "agent change\n")
+	writeTestFile(t, ".ralph/logs/iter2.jsonl", "{}\n")
+
+	cfg := DefaultConfig()
+	cfg.SessionID = "s1"
+	patch, err := revertIteration(cfg, 1, startHead, snapshot)
+	if err != nil {
+		t.Fatalf("revertIteration: %v", err)
+	}
+
+	if head, _ := getHeadCommit(""); head != startHead {
+		t.Errorf("HEAD = %s, want %s", head, startHead)
+	}
+	for _, path := range []string{"committed.txt", "added.txt"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should have been removed", path)
+		}
+	}
+	for path, want := range map[string]string{
+		"tracked.txt": "edited before the iteration\n",
+		"notes.txt":   "untracked before the iteration\n",
+	} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if _, err := os.Stat(".ralph/logs/iter2.jsonl"); err != nil {
+		t.Errorf("local state should survive a revert: %v", err)
+	}
+
+	content, err := os.ReadFile(patch)
+	if err != nil {
+		t.Fatalf("rejected patch: %v", err)
+	}
+	for _, path := range []string{"committed.txt", "added.txt"} {
+		if !strings.Contains(string(content), path) {
+			t.Errorf("rejected patch is missing %s", path)
+		}
+	}
+	if strings.Contains(string(content), ".ralph/logs") {
+		t.Error("rejected patch should not include local state")
+	}
+}
@@ -0,0 +1,482 @@
+package loop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the path to the project-level config file
+const ProjectConfigFile = ".ralph/config.yaml"
+
+// ConfigOverlay is a partial Config where nil fields leave the underlying value unchanged.
+// Config files, environment variables and flags are each expressed as an overlay.
+type ConfigOverlay struct {
+	PromptFile        *string                 `yaml:"prompt_file"`
+	MaxIterations     *int                    `yaml:"max_iterations"`
+	NoPush            *bool                   `yaml:"no_push"`
+	AutoCommit        *bool                   `yaml:"auto_commit"`
+	Branch            *string                 `yaml:"branch"`
+	ProtectedBranches []string                `yaml:"protected_branches"`
+	AllowProtected    *bool                   `yaml:"allow_protected"`
+	Worktree          *bool                   `yaml:"worktree"`
+	WorktreeCleanup   *string                 `yaml:"worktree_cleanup"`
+	OnVerifyFail      *string                 `yaml:"on_verify_fail"`
+	Agent             *string                 `yaml:"agent"`
+	Model             *string                 `yaml:"model"`
+	Mode              *string                 `yaml:"mode"`
+	RLMMaxDepth       *int                    `yaml:"max_depth"`
+	VerifyEnabled     *bool                   `yaml:"verify"`
+	VerifyCommands    []string                `yaml:"verify_commands"`
+	VerifyOutputLimit *int                    `yaml:"verify_output_limit"`
+	Command           *CommandConfig          `yaml:"command"`
+	Retry             RetryOverlay            `yaml:"retry"`
+	IterationTimeout  *time.Duration          `yaml:"iteration_timeout"`
+	IdleTimeout       *time.Duration          `yaml:"idle_timeout"`
+	OnTimeout         *string                 `yaml:"on_timeout"`
+	MaxCost           *float64                `yaml:"max_cost"`
+	MaxTokens         *int                    `yaml:"max_tokens"`
+	BudgetWarnPercent *int                    `yaml:"budget_warn_percent"`
+	Pricing           map[string]ModelPricing `yaml:"pricing"`
+	Vars              map[string]string       `yaml:"vars"`
+	Tasks             *bool                   `yaml:"tasks"`
+	TaskMaxIterations *int                    `yaml:"task_max_iterations"`
+}
+
+// RetryOverlay is a partial RetryPolicy
+type RetryOverlay struct {
+	MaxAttempts *int           `yaml:"max_attempts"`
+	BackoffBase *time.Duration `yaml:"backoff_base"`
+	BackoffCap  *time.Duration `yaml:"backoff_cap"`
+	Jitter      *float64       `yaml:"jitter"`
+}
+
+// FileConfig represents the contents of a goralph config file
+type FileConfig struct {
+	ConfigOverlay `yaml:",inline"`
+	Profiles      map[string]ConfigOverlay `yaml:"profiles"`
+}
+
+// BuiltinProfiles returns the profiles available without any config file.
+// Config files can override these by defining a profile with the same name.
+func BuiltinProfiles() map[string]ConfigOverlay {
+	unlimited, three := 0, 3
+	rlm := string(ModeRLM)
+	yes, no := true, false
+	return map[string]ConfigOverlay{
+		"overnight": {
+			MaxIterations: &unlimited,
+			Mode:          &rlm,
+			VerifyEnabled: &yes,
+			NoPush:        &no,
+		},
+		"dry": {
+			MaxIterations: &three,
+			NoPush:        &yes,
+		},
+	}
+}
+
+// DefaultConfig returns the built-in configuration used before any overlay is applied
+func DefaultConfig() Config {
+	return Config{
+		PromptFile:        PromptFile,
+		Agent:             AgentClaude,
+		Mode:              ModeRalph,
+		RLMMaxDepth:       3,
+		Retry:             DefaultRetryPolicy(),
+		OnTimeout:         TimeoutContinue,
+		BudgetWarnPercent: 80,
+		Pricing:           DefaultPricing(),
+		ProtectedBranches: []string{"main", "master"},
+		WorktreeCleanup:   WorktreeKeep,
+		OnVerifyFail:      VerifyFailKeep,
+		VerifyOutputLimit: 4000,
+	}
+}
+
+// UserConfigPath returns the path to the user-level config file
+// ($XDG_CONFIG_HOME/goralph/config.yaml, falling back to ~/.config/goralph/config.yaml)
+func UserConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "goralph", "config.yaml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "goralph", "config.yaml")
+}
+
+// LoadConfig resolves the configuration from defaults, the user config file,
+// the project config file, the named profile (if any) and GORALPH_* environment
+// variables, in that order. Flags are applied by the caller on top of the returned config.
+func LoadConfig(profile string) (Config, error) {
+	cfg := DefaultConfig()
+	profiles := BuiltinProfiles()
+
+	for _, path := range []string{UserConfigPath(), ProjectConfigFile} {
+		if path == "" {
+			continue
+		}
+		fileCfg, err := LoadConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if fileCfg == nil {
+			continue
+		}
+		if err := fileCfg.Apply(&cfg); err != nil {
+			return Config{}, fmt.Errorf("invalid config in %s: %w", path, err)
+		}
+		for name, overlay := range fileCfg.Profiles {
+			profiles[name] = overlay
+		}
+	}
+
+	if profile != "" {
+		overlay, ok := profiles[profile]
+		if !ok {
+			return Config{}, fmt.Errorf("unknown profile: %q (available: %s)", profile, strings.Join(profileNames(profiles), ", "))
+		}
+		if err := overlay.Apply(&cfg); err != nil {
+			return Config{}, fmt.Errorf("invalid profile %q: %w", profile, err)
+		}
+		cfg.Profile = profile
+	}
+
+	envOverlay, err := EnvOverlay()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := envOverlay.Apply(&cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid environment config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// LoadConfigFile reads a config file. It returns nil without error if the file does not exist.
+func LoadConfigFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var fileCfg FileConfig
+	if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return &fileCfg, nil
+}
+
+// EnvOverlay builds an overlay from GORALPH_* environment variables
+func EnvOverlay() (ConfigOverlay, error) {
+	var o ConfigOverlay
+
+	if v := os.Getenv("GORALPH_PROMPT_FILE"); v != "" {
+		o.PromptFile = &v
+	}
+	if v := os.Getenv("GORALPH_AGENT"); v != "" {
+		o.Agent = &v
+	}
+	if v := os.Getenv("GORALPH_MODEL"); v != "" {
+		o.Model = &v
+	}
+	if v := os.Getenv("GORALPH_MODE"); v != "" {
+		o.Mode = &v
+	}
+	if v := os.Getenv("GORALPH_BRANCH"); v != "" {
+		o.Branch = &v
+	}
+	if v := os.Getenv("GORALPH_VERIFY_COMMANDS"); v != "" {
+		// One command per line, since a command may itself contain commas
+		o.VerifyCommands = splitLines(v)
+	}
+
+	var err error
+	if o.MaxIterations, err = envInt("GORALPH_MAX_ITERATIONS"); err != nil {
+		return o, err
+	}
+	if o.RLMMaxDepth, err = envInt("GORALPH_MAX_DEPTH"); err != nil {
+		return o, err
+	}
+	if o.NoPush, err = envBool("GORALPH_NO_PUSH"); err != nil {
+		return o, err
+	}
+	if o.AutoCommit, err = envBool("GORALPH_AUTO_COMMIT"); err != nil {
+		return o, err
+	}
+	if o.VerifyEnabled, err = envBool("GORALPH_VERIFY"); err != nil {
+		return o, err
+	}
+	if o.VerifyOutputLimit, err = envInt("GORALPH_VERIFY_OUTPUT_LIMIT"); err != nil {
+		return o, err
+	}
+	if o.Retry.MaxAttempts, err = envInt("GORALPH_RETRY_MAX_ATTEMPTS"); err != nil {
+		return o, err
+	}
+	if o.MaxCost, err = envFloat("GORALPH_MAX_COST"); err != nil {
+		return o, err
+	}
+	if o.MaxTokens, err = envInt("GORALPH_MAX_TOKENS"); err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+// Apply overlays the non-nil fields onto cfg, validating agent and mode values
+func (o ConfigOverlay) Apply(cfg *Config) error {
+	if o.PromptFile != nil {
+		cfg.PromptFile = *o.PromptFile
+	}
+	if o.MaxIterations != nil {
+		if *o.MaxIterations < 0 {
+			return fmt.Errorf("max_iterations must not be negative: %d", *o.MaxIterations)
+		}
+		cfg.MaxIterations = *o.MaxIterations
+	}
+	if o.NoPush != nil {
+		cfg.NoPush = *o.NoPush
+	}
+	if o.AutoCommit != nil {
+		cfg.AutoCommit = *o.AutoCommit
+	}
+	if o.Branch != nil {
+		cfg.Branch = *o.Branch
+	}
+	if o.ProtectedBranches != nil {
+		cfg.ProtectedBranches = o.ProtectedBranches
+	}
+	if o.AllowProtected != nil {
+		cfg.AllowProtected = *o.AllowProtected
+	}
+	if o.Worktree != nil {
+		cfg.Worktree = *o.Worktree
+	}
+	if o.WorktreeCleanup != nil {
+		if err := ValidateWorktreeCleanup(*o.WorktreeCleanup); err != nil {
+			return err
+		}
+		cfg.WorktreeCleanup = *o.WorktreeCleanup
+	}
+	if o.Agent != nil {
+		// A comma-separated list defines the fallback chain, e.g. "claude,codex"
+		names := splitList(*o.Agent)
+		if len(names) == 0 {
+			return fmt.Errorf("agent must not be empty")
+		}
+		agents := make([]AgentProvider, 0, len(names))
+		for _, name := range names {
+			agent, err := ValidateAgentProvider(name)
+			if err != nil {
+				return err
+			}
+			agents = append(agents, agent)
+		}
+		cfg.Agent = agents[0]
+		cfg.FallbackAgents = agents[1:]
+	}
+	if o.Model != nil {
+		cfg.Model = *o.Model
+	}
+	if o.Mode != nil {
+		mode, err := ValidateMode(*o.Mode)
+		if err != nil {
+			return err
+		}
+		cfg.Mode = mode
+	}
+	if o.RLMMaxDepth != nil {
+		cfg.RLMMaxDepth = *o.RLMMaxDepth
+	}
+	if o.VerifyEnabled != nil {
+		cfg.VerifyEnabled = *o.VerifyEnabled
+	}
+	if o.VerifyCommands != nil {
+		cfg.VerifyCommands = o.VerifyCommands
+	}
+	if o.VerifyOutputLimit != nil {
+		if *o.VerifyOutputLimit < 0 {
+			return fmt.Errorf("verify_output_limit must not be negative: %d", *o.VerifyOutputLimit)
+		}
+		cfg.VerifyOutputLimit = *o.VerifyOutputLimit
+	}
+	if o.Command != nil {
+		cfg.Command = *o.Command
+	}
+	if o.IterationTimeout != nil {
+		cfg.IterationTimeout = *o.IterationTimeout
+	}
+	if o.IdleTimeout != nil {
+		cfg.IdleTimeout = *o.IdleTimeout
+	}
+	if o.OnTimeout != nil {
+		if *o.OnTimeout != TimeoutContinue && *o.OnTimeout != TimeoutStop {
+			return fmt.Errorf("unknown on_timeout policy: %q (valid options: continue, stop)", *o.OnTimeout)
+		}
+		cfg.OnTimeout = *o.OnTimeout
+	}
+	if o.OnVerifyFail != nil {
+		switch *o.OnVerifyFail {
+		case VerifyFailKeep, VerifyFailRevert, VerifyFailFixup:
+		default:
+			return fmt.Errorf("unknown on_verify_fail policy: %q (valid options: keep, revert, fixup)", *o.OnVerifyFail)
+		}
+		cfg.OnVerifyFail = *o.OnVerifyFail
+	}
+	if o.MaxCost != nil {
+		if *o.MaxCost < 0 {
+			return fmt.Errorf("max_cost must not be negative: %v", *o.MaxCost)
+		}
+		cfg.MaxCost = *o.MaxCost
+	}
+	if o.MaxTokens != nil {
+		if *o.MaxTokens < 0 {
+			return fmt.Errorf("max_tokens must not be negative: %d", *o.MaxTokens)
+		}
+		cfg.MaxTokens = *o.MaxTokens
+	}
+	if o.BudgetWarnPercent != nil {
+		if *o.BudgetWarnPercent < 0 || *o.BudgetWarnPercent > 100 {
+			return fmt.Errorf("budget_warn_percent must be between 0 and 100: %d", *o.BudgetWarnPercent)
+		}
+		cfg.BudgetWarnPercent = *o.BudgetWarnPercent
+	}
+	if o.Pricing != nil {
+		// Merge per model so a config file only needs to list the rates it changes
+		pricing := make(map[string]ModelPricing, len(cfg.Pricing)+len(o.Pricing))
+		for model, rates := range cfg.Pricing {
+			pricing[model] = rates
+		}
+		for model, rates := range o.Pricing {
+			pricing[model] = rates
+		}
+		cfg.Pricing = pricing
+	}
+	if o.Tasks != nil {
+		cfg.Tasks = *o.Tasks
+	}
+	if o.TaskMaxIterations != nil {
+		if *o.TaskMaxIterations < 0 {
+			return fmt.Errorf("task_max_iterations must not be negative: %d", *o.TaskMaxIterations)
+		}
+		cfg.TaskMaxIterations = *o.TaskMaxIterations
+	}
+	if o.Vars != nil {
+		// Merge so flags can add variables to those set in config files
+		vars := make(map[string]string, len(cfg.Vars)+len(o.Vars))
+		for key, value := range cfg.Vars {
+			vars[key] = value
+		}
+		for key, value := range o.Vars {
+			vars[key] = value
+		}
+		cfg.Vars = vars
+	}
+	return o.Retry.Apply(&cfg.Retry)
+}
+
+// Apply overlays the non-nil fields onto policy
+func (o RetryOverlay) Apply(policy *RetryPolicy) error {
+	if o.MaxAttempts != nil {
+		if *o.MaxAttempts < 1 {
+			return fmt.Errorf("retry.max_attempts must be at least 1: %d", *o.MaxAttempts)
+		}
+		policy.MaxAttempts = *o.MaxAttempts
+	}
+	if o.BackoffBase != nil {
+		policy.BackoffBase = *o.BackoffBase
+	}
+	if o.BackoffCap != nil {
+		policy.BackoffCap = *o.BackoffCap
+	}
+	if o.Jitter != nil {
+		if *o.Jitter < 0 || *o.Jitter > 1 {
+			return fmt.Errorf("retry.jitter must be between 0 and 1: %v", *o.Jitter)
+		}
+		policy.Jitter = *o.Jitter
+	}
+	return nil
+}
+
+// profileNames returns the sorted names of the given profiles
+func profileNames(profiles map[string]ConfigOverlay) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envInt parses an integer environment variable, returning nil if unset
+func envInt(name string) (*int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q is not an integer", name, v)
+	}
+	return &n, nil
+}
+
+// envFloat parses a float environment variable, returning nil if unset
+func envFloat(name string) (*float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q is not a number", name, v)
+	}
+	return &f, nil
+}
+
+// envBool parses a boolean environment variable, returning nil if unset
+func envBool(name string) (*bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q is not a boolean", name, v)
+	}
+	return &b, nil
+}
+
+// splitList splits a comma-separated list, trimming whitespace and dropping empty entries
+func splitList(s string) []string {
+	return splitTrimmed(s, ",")
+}
+
+// splitLines splits s into lines, trimming whitespace and dropping empty lines
+func splitLines(s string) []string {
+	return splitTrimmed(s, "\n")
+}
+
+// splitTrimmed splits s on sep, trimming whitespace and dropping empty entries
+func splitTrimmed(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,19 @@
+package loop
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvOverlayVerifyCommands(t *testing.T) {
+	t.Setenv("GORALPH_VERIFY_COMMANDS", "go test ./...\n\n  jq -r '.a, .b' out.json  \r\nmake lint\n")
+
+	overlay, err := EnvOverlay()
+	if err != nil {
+		t.Fatalf("EnvOverlay: %v", err)
+	}
+	want := []string{"go test ./...", "jq -r '.a, .b' out.json", "make lint"}
+	if !reflect.DeepEqual(overlay.VerifyCommands, want) {
+		t.Errorf("VerifyCommands = %q, want %q", overlay.VerifyCommands, want)
+	}
+}
@@ -0,0 +1,310 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckStatus is the result of a diagnostic check
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// DiagnosticCheck is the outcome of a single environment check
+type DiagnosticCheck struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+}
+
+// promptSizeWarn is the prompt size above which a warning is reported, since
+// the prompt is resent every iteration
+const promptSizeWarn = 100 * 1024
+
+// versionTimeout bounds how long an agent's --version may take
+const versionTimeout = 5 * time.Second
+
+// RunDiagnostics checks the environment the loop depends on: agent binaries,
+// git state, .ralph/ writability, the prompt file and verification commands.
+// Agent versions are only queried when versions is set, since it runs each binary.
+func RunDiagnostics(cfg Config, versions bool) []DiagnosticCheck {
+	var checks []DiagnosticCheck
+	checks = append(checks, checkAgents(cfg, versions)...)
+	checks = append(checks, checkGit(cfg)...)
+	checks = append(checks, checkRalphDir())
+	if cfg.Tasks {
+		checks = append(checks, checkTaskQueue()...)
+	} else {
+		checks = append(checks, checkPromptFile(cfg.PromptFile))
+	}
+	checks = append(checks, checkVerification(cfg)...)
+	return checks
+}
+
+// HasFailures reports whether any check failed
+func HasFailures(checks []DiagnosticCheck) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// preflight runs the diagnostics without agent versions, printing any warnings
+// and failures, and returns an error if a check failed
+func preflight(cfg Config) error {
+	checks := RunDiagnostics(cfg, false)
+	var problems []DiagnosticCheck
+	for _, c := range checks {
+		if c.Status != CheckPass {
+			problems = append(problems, c)
+		}
+	}
+	FormatDiagnostics(cfg.Output, problems)
+	if HasFailures(checks) {
+		return fmt.Errorf("preflight checks failed (run goralph doctor for details)")
+	}
+	return nil
+}
+
+// agentBinary returns the executable an agent provider runs
+func agentBinary(agent AgentProvider, command CommandConfig) string {
+	if agent == AgentCommand {
+		if len(command.Args) == 0 {
+			return ""
+		}
+		return command.Args[0]
+	}
+	return string(agent)
+}
+
+// checkAgents checks that the primary and fallback agent binaries are installed.
+// A missing primary agent fails; a missing fallback only warns.
+func checkAgents(cfg Config, versions bool) []DiagnosticCheck {
+	agents := append([]AgentProvider{cfg.Agent}, cfg.FallbackAgents...)
+	checks := make([]DiagnosticCheck, 0, len(agents))
+	for i, agent := range agents {
+		check := DiagnosticCheck{Name: fmt.Sprintf("agent %s", agent)}
+		missing := CheckFail
+		if i > 0 {
+			check.Name = fmt.Sprintf("fallback agent %s", agent)
+			missing = CheckWarn
+		}
+
+		binary := agentBinary(agent, cfg.Command)
+		if binary == "" {
+			check.Status = missing
+			check.Detail = "no command configured (set command.args in the config file)"
+			checks = append(checks, check)
+			continue
+		}
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			check.Status = missing
+			check.Detail = fmt.Sprintf("%s not found in PATH", binary)
+			checks = append(checks, check)
+			continue
+		}
+
+		check.Status = CheckPass
+		check.Detail = path
+		if versions {
+			if version := agentVersion(path); version != "" {
+				check.Detail = fmt.Sprintf("%s (%s)", version, path)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// agentVersion returns the first line of a binary's --version output, or "" if it fails
+func agentVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line
+}
+
+// checkGit checks the git repository, branch, remote and working tree. Problems
+// that only affect pushing are warnings when --no-push is set.
+func checkGit(cfg Config) []DiagnosticCheck {
+	if !isGitRepo() {
+		return []DiagnosticCheck{{Name: "git repository", Status: CheckFail, Detail: "not inside a git work tree"}}
+	}
+	checks := []DiagnosticCheck{{Name: "git repository", Status: CheckPass, Detail: "inside a git work tree"}}
+
+	pushProblem := CheckFail
+	if cfg.NoPush {
+		pushProblem = CheckWarn
+	}
+
+	branch, err := getCurrentBranch("")
+	switch {
+	case err != nil:
+		checks = append(checks, DiagnosticCheck{Name: "git branch", Status: CheckFail, Detail: err.Error()})
+	case sessionBranch(cfg) != "":
+		checks = append(checks, checkSessionBranch(cfg, branch))
+	case branch == "":
+		checks = append(checks, DiagnosticCheck{Name: "git branch", Status: pushProblem, Detail: "detached HEAD; check out a branch to push changes"})
+	case isProtectedBranch(branch, cfg.ProtectedBranches) && !cfg.AllowProtected:
+		checks = append(checks, DiagnosticCheck{Name: "git branch", Status: CheckFail, Detail: branch + " is protected; use --branch auto to work on a session branch, or --allow-protected"})
+	default:
+		checks = append(checks, DiagnosticCheck{Name: "git branch", Status: CheckPass, Detail: branch})
+	}
+
+	if hasRemote("origin") {
+		checks = append(checks, DiagnosticCheck{Name: "git remote", Status: CheckPass, Detail: "origin"})
+	} else {
+		checks = append(checks, DiagnosticCheck{Name: "git remote", Status: pushProblem, Detail: "no origin remote; use --no-push or add a remote"})
+	}
+
+	status, err := getStatusPorcelain("")
+	switch {
+	case err != nil:
+		checks = append(checks, DiagnosticCheck{Name: "working tree", Status: CheckWarn, Detail: err.Error()})
+	case status != "":
+		changed := len(strings.Split(status, "\n"))
+		checks = append(checks, DiagnosticCheck{Name: "working tree", Status: CheckWarn, Detail: fmt.Sprintf("%d uncommitted change(s) may be committed by the agent", changed)})
+	default:
+		checks = append(checks, DiagnosticCheck{Name: "working tree", Status: CheckPass, Detail: "clean"})
+	}
+	return checks
+}
+
+// checkSessionBranch checks the branch the loop will switch to before the first iteration
+func checkSessionBranch(cfg Config, current string) DiagnosticCheck {
+	target := sessionBranch(cfg)
+	if usesAutoBranch(cfg) {
+		// The session ID isn't final until a resumed session is resolved
+		target = "ralph/<session-id>"
+	}
+	if current == "" {
+		current = "detached HEAD"
+	}
+	check := DiagnosticCheck{Name: "git branch", Status: CheckPass, Detail: fmt.Sprintf("%s (switching from %s)", target, current)}
+	if cfg.Worktree {
+		check.Detail = fmt.Sprintf("%s (in a worktree under %s)", target, WorktreesDir)
+	}
+	if isProtectedBranch(target, cfg.ProtectedBranches) && !cfg.AllowProtected {
+		check.Status = CheckFail
+		check.Detail = target + " is protected; use --branch auto to work on a session branch, or --allow-protected"
+	}
+	return check
+}
+
+// checkRalphDir checks that .ralph/ exists or can be created, and is writable
+func checkRalphDir() DiagnosticCheck {
+	check := DiagnosticCheck{Name: ".ralph directory"}
+	if err := os.MkdirAll(".ralph", 0755); err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	f, err := os.CreateTemp(".ralph", ".doctor-*")
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("not writable: %v", err)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	check.Status = CheckPass
+	check.Detail = "writable"
+	return check
+}
+
+// checkPromptFile checks that the prompt file exists and has a sensible size
+func checkPromptFile(promptFile string) DiagnosticCheck {
+	check := DiagnosticCheck{Name: "prompt file"}
+	info, err := os.Stat(promptFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s not found (run goralph init)", promptFile)
+	case err != nil:
+		check.Status = CheckFail
+		check.Detail = err.Error()
+	case info.Size() == 0:
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("%s is empty", promptFile)
+	case info.Size() > promptSizeWarn:
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("%s is %s bytes and is resent every iteration", promptFile, formatNumber(int(info.Size())))
+	default:
+		check.Status = CheckPass
+		check.Detail = fmt.Sprintf("%s (%s bytes)", filepath.ToSlash(promptFile), formatNumber(int(info.Size())))
+	}
+	return check
+}
+
+// checkTaskQueue checks the task directory and the prompt file of the pending task
+func checkTaskQueue() []DiagnosticCheck {
+	queue, err := LoadTaskQueue()
+	if err != nil {
+		return []DiagnosticCheck{{Name: "task queue", Status: CheckFail, Detail: err.Error()}}
+	}
+	task, ok := queue.Pending()
+	if !ok {
+		return []DiagnosticCheck{{Name: "task queue", Status: CheckWarn, Detail: fmt.Sprintf("all %d task(s) complete (delete %s to start over)", len(queue.Tasks), QueueFile)}}
+	}
+	return []DiagnosticCheck{
+		{Name: "task queue", Status: CheckPass, Detail: fmt.Sprintf("%d of %d task(s) remaining, next: %s", queue.Remaining(), len(queue.Tasks), task)},
+		checkPromptFile(taskPath(task)),
+	}
+}
+
+// checkVerification reports the verification commands that would run and
+// whether their executables are installed
+func checkVerification(cfg Config) []DiagnosticCheck {
+	commands := cfg.VerifyCommands
+	source := "configured"
+	if len(commands) == 0 {
+		commands = DetectProjectType()
+		source = "detected"
+	}
+
+	if len(commands) == 0 {
+		status := CheckPass
+		if cfg.VerifyEnabled {
+			status = CheckWarn
+		}
+		return []DiagnosticCheck{{Name: "verification", Status: status, Detail: "no verification commands detected for project type"}}
+	}
+
+	detail := fmt.Sprintf("%s: %s", source, strings.Join(commands, ", "))
+	if !cfg.VerifyEnabled {
+		detail += " (disabled; enable with --verify)"
+	}
+	checks := []DiagnosticCheck{{Name: "verification", Status: CheckPass, Detail: detail}}
+
+	if !cfg.VerifyEnabled {
+		return checks
+	}
+	for _, command := range commands {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			checks = append(checks, DiagnosticCheck{Name: "verification", Status: CheckWarn, Detail: fmt.Sprintf("%s not found in PATH", fields[0])})
+		}
+	}
+	return checks
+}
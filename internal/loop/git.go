@@ -1,15 +1,39 @@
 package loop
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
+	"strconv"
 	"strings"
 )
 
-// getCurrentBranch returns the current git branch name
-func getCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
+// gitCommand builds a git command that runs in dir (the current directory if empty)
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// gitCommandContext builds a git command in dir for a long-running operation such as a
+// push. It runs in its own process group, so a first Ctrl-C doesn't interrupt it; it is
+// signaled only when ctx is canceled by a second Ctrl-C.
+func gitCommandContext(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	setProcessGroup(ctx, cmd)
+	cmd.WaitDelay = killGracePeriod
+	return cmd
+}
+
+// getCurrentBranch returns the git branch checked out in dir
+func getCurrentBranch(dir string) (string, error) {
+	cmd := gitCommand(dir, "branch", "--show-current")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", err
@@ -17,21 +41,265 @@ func getCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// pushChanges pushes commits to the remote branch
-func pushChanges(branch string) error {
+// pushChanges pushes the commits of the repository in dir to the remote branch.
+// A push rejected as non-fast-forward is returned as a RetryableError.
+func pushChanges(ctx context.Context, dir, branch string) error {
 	// Try to push
-	cmd := exec.Command("git", "push", "origin", branch)
+	var stderr bytes.Buffer
+	cmd := gitCommandContext(ctx, dir, "push", "origin", branch)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
 	if err := cmd.Run(); err != nil {
 		// If push failed, try to create remote branch
 		fmt.Println("Failed to push. Creating remote branch...")
-		cmd = exec.Command("git", "push", "-u", "origin", branch)
+		cmd = gitCommandContext(ctx, dir, "push", "-u", "origin", branch)
 		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		if err := cmd.Run(); err != nil {
+			if isNonFastForward(stderr.String()) {
+				return &RetryableError{Err: fmt.Errorf("push rejected (non-fast-forward): %w", err)}
+			}
+			return err
+		}
 	}
 
 	return nil
 }
+
+// isNonFastForward reports whether git push output indicates the remote has diverged
+func isNonFastForward(output string) bool {
+	return strings.Contains(output, "non-fast-forward") || strings.Contains(output, "fetch first")
+}
+
+// pullRebase rebases local commits onto the remote branch so a rejected push can be retried
+func pullRebase(ctx context.Context, dir, branch string) error {
+	cmd := gitCommandContext(ctx, dir, "pull", "--rebase", "origin", branch)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// Leave the tree as it was rather than mid-rebase
+		gitCommandContext(context.Background(), dir, "rebase", "--abort").Run()
+		return fmt.Errorf("failed to rebase onto origin/%s: %w", branch, err)
+	}
+	return nil
+}
+
+// getHeadCommit returns the full hash of the HEAD commit in dir
+func getHeadCommit(dir string) (string, error) {
+	output, err := gitCommand(dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// countCommitsSince returns the number of commits on HEAD in dir after base
+func countCommitsSince(dir, base string) (int, error) {
+	output, err := gitCommand(dir, "rev-list", "--count", base+"..HEAD").Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// countFilesChangedSince returns the number of files that differ between base and HEAD in dir
+func countFilesChangedSince(dir, base string) (int, error) {
+	output, err := gitCommand(dir, "diff", "--name-only", base, "HEAD").Output()
+	if err != nil {
+		return 0, err
+	}
+	return len(strings.Fields(string(output))), nil
+}
+
+// isGitRepo reports whether the current directory is inside a git work tree
+func isGitRepo() bool {
+	output, err := exec.Command("git", "rev-parse", "--is-inside-work-tree").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// hasRemote reports whether a git remote with the given name is configured
+func hasRemote(name string) bool {
+	return exec.Command("git", "remote", "get-url", name).Run() == nil
+}
+
+// getStatusPorcelain returns the short status of uncommitted changes in dir (empty if the tree
+// is clean), optionally limited to the given pathspecs
+func getStatusPorcelain(dir string, pathspecs ...string) (string, error) {
+	args := []string{"status", "--porcelain"}
+	if len(pathspecs) > 0 {
+		args = append(append(args, "--"), pathspecs...)
+	}
+	output, err := gitCommand(dir, args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// stageChanges stages every change in dir matching the pathspecs, including untracked files
+func stageChanges(dir string, pathspecs ...string) error {
+	args := append([]string{"add", "-A", "--"}, pathspecs...)
+	return runGit(gitCommand(dir, args...))
+}
+
+// ignoredPaths returns which of the paths in dir are gitignored. Errors are treated
+// as nothing being ignored.
+func ignoredPaths(dir string, paths ...string) map[string]bool {
+	ignored := make(map[string]bool)
+	// Exit status 1 means none of the paths is ignored
+	output, _ := gitCommand(dir, append([]string{"check-ignore", "--"}, paths...)...).Output()
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ignored[line] = true
+		}
+	}
+	return ignored
+}
+
+// hasStagedChanges reports whether the index in dir differs from HEAD
+func hasStagedChanges(dir string) (bool, error) {
+	err := gitCommand(dir, "diff", "--cached", "--quiet").Run()
+	if err == nil {
+		return false, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
+// commitStaged commits the staged changes in dir with the given message
+func commitStaged(dir, message string) error {
+	var stderr bytes.Buffer
+	cmd := gitCommand(dir, "commit", "-q", "-F", "-")
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// BranchAuto selects a dedicated ralph/<session-id> branch for the session
+const BranchAuto = "auto"
+
+// sessionBranch returns the branch a session should work on, or "" to stay on the checked out branch
+func sessionBranch(cfg Config) string {
+	if usesAutoBranch(cfg) {
+		return "ralph/" + cfg.SessionID
+	}
+	return cfg.Branch
+}
+
+// usesAutoBranch reports whether the session works on a ralph/<session-id> branch. A worktree
+// can't check out the branch the main checkout is on, so it defaults to one.
+func usesAutoBranch(cfg Config) bool {
+	return cfg.Branch == BranchAuto || (cfg.Worktree && cfg.Branch == "")
+}
+
+// isProtectedBranch reports whether a branch matches one of the protected name patterns
+// (globs such as "release/*" are supported)
+func isProtectedBranch(branch string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// branchExists reports whether a local branch exists
+func branchExists(branch string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil
+}
+
+// switchToBranch checks out a branch, creating it from HEAD if it doesn't exist.
+// It reports whether the branch was created.
+func switchToBranch(branch string) (bool, error) {
+	created := !branchExists(branch)
+	args := []string{"checkout", "-q", branch}
+	if created {
+		args = []string{"checkout", "-q", "-b", branch}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return created, nil
+}
+
+// snapshotTree writes the working tree in dir matching the pathspecs, including
+// uncommitted and untracked files, as a git tree object and returns its hash.
+// A temporary index is used, so the real index is left untouched.
+func snapshotTree(dir string, pathspecs ...string) (string, error) {
+	f, err := os.CreateTemp("", "goralph-index-*")
+	if err != nil {
+		return "", err
+	}
+	index := f.Name()
+	f.Close()
+	// Git refuses to read an empty index file, so start from none
+	os.Remove(index)
+	defer os.Remove(index)
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+index)
+	steps := [][]string{
+		{"read-tree", "HEAD"},
+		append([]string{"add", "-A", "--"}, pathspecs...),
+	}
+	for _, args := range steps {
+		cmd := gitCommand(dir, args...)
+		cmd.Env = env
+		if err := runGit(cmd); err != nil {
+			return "", err
+		}
+	}
+	cmd := gitCommand(dir, "write-tree")
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// restoreSnapshot moves the branch in dir back to commit and the working tree back
+// to a snapshotTree tree, removing files added since. It returns the binary diff of
+// everything it discarded.
+func restoreSnapshot(dir, commit, tree string, pathspecs ...string) ([]byte, error) {
+	// Capture the current state in the index, on top of the original commit
+	if err := runGit(gitCommand(dir, "reset", "-q", "--soft", commit)); err != nil {
+		return nil, err
+	}
+	if err := stageChanges(dir, pathspecs...); err != nil {
+		return nil, err
+	}
+	current, err := gitCommand(dir, "write-tree").Output()
+	if err != nil {
+		return nil, err
+	}
+	patch, err := gitCommand(dir, "diff", "--binary", tree, strings.TrimSpace(string(current))).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the snapshot out, then unstage it so earlier uncommitted changes stay uncommitted
+	if err := runGit(gitCommand(dir, "read-tree", "--reset", "-u", tree)); err != nil {
+		return patch, err
+	}
+	return patch, runGit(gitCommand(dir, "reset", "-q"))
+}
+
+// runGit runs a git command, including its stderr in the returned error
+func runGit(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// errInterrupted is the context cause when the user forces an abort with a second Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// interruptError carries the signal to forward to the agent's process group
+type interruptError struct {
+	sig os.Signal
+}
+
+func (e *interruptError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.sig)
+}
+
+func (e *interruptError) Is(target error) bool {
+	return target == errInterrupted
+}
+
+// interruptHandler implements two-stage Ctrl-C handling: the first SIGINT/SIGTERM
+// requests a stop after the current iteration, the second cancels the session
+// context, which forwards the signal to the running agent's process group.
+type interruptHandler struct {
+	signals       chan os.Signal
+	cancel        context.CancelCauseFunc
+	stopRequested atomic.Bool
+	done          chan struct{}
+}
+
+// newInterruptHandler starts listening for interrupt signals until Stop is called
+func newInterruptHandler(w io.Writer, cancel context.CancelCauseFunc) *interruptHandler {
+	h := &interruptHandler{
+		signals: make(chan os.Signal, 2),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	signal.Notify(h.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for {
+			select {
+			case sig := <-h.signals:
+				if h.stopRequested.CompareAndSwap(false, true) {
+					fmt.Fprintln(w)
+					fmt.Fprintln(w, toolActiveStyle.Render("Stopping after this iteration (press Ctrl-C again to abort now)"))
+					continue
+				}
+				fmt.Fprintln(w)
+				fmt.Fprintln(w, errorStyle.Render("Aborting: stopping agent..."))
+				h.cancel(&interruptError{sig: sig})
+			case <-h.done:
+				return
+			}
+		}
+	}()
+
+	return h
+}
+
+// StopRequested reports whether the user asked to stop after the current iteration
+func (h *interruptHandler) StopRequested() bool {
+	return h.stopRequested.Load()
+}
+
+// Stop restores default signal handling
+func (h *interruptHandler) Stop() {
+	signal.Stop(h.signals)
+	close(h.done)
+}
+
+// cancelSignal returns the signal to send the agent's process group when ctx is canceled:
+// the user's signal for an interrupt, otherwise a kill (e.g. for timeouts)
+func cancelSignal(ctx context.Context) os.Signal {
+	var intErr *interruptError
+	if errors.As(context.Cause(ctx), &intErr) {
+		return intErr.sig
+	}
+	return os.Kill
+}
@@ -1,7 +1,11 @@
 package loop
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -31,14 +35,53 @@ func Run(cfg Config) error {
 		cfg.Mode = ModeRalph
 	}
 
-	// Create provider once at start
-	provider, err := NewProvider(cfg.Agent)
+	if cfg.SessionID == "" {
+		cfg.SessionID = GenerateSessionID()
+	}
+
+	// Create the provider chain once at start: the primary agent followed by fallbacks
+	providers, err := NewProviderChain(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create provider: %w", err)
 	}
+	provider := providers[0]
+
+	// In task-queue mode, work on the pending task, resuming the session that was working it
+	var queue *TaskQueue
+	if cfg.Tasks {
+		queue, err = LoadTaskQueue()
+		if err != nil {
+			return err
+		}
+		task, ok := queue.Pending()
+		if !ok {
+			FormatQueueComplete(cfg.Output, len(queue.State.Completed))
+			return nil
+		}
+		cfg.PromptFile = taskPath(task)
+		if current := queue.Current(); current != nil && cfg.Resume == "" {
+			if _, err := LoadSessionMeta(current.SessionID); err == nil {
+				cfg.Resume = current.SessionID
+			}
+		}
+	}
+
+	// Resume a previous session's plan, mode, branch, worktree and iteration counter
+	var resumed *SessionMeta
+	if cfg.Resume != "" {
+		resumed, err = resumeSession(&cfg)
+		if err != nil {
+			return fmt.Errorf("failed to resume session: %w", err)
+		}
+	}
+
+	// Catch a missing agent, detached HEAD, missing remote or prompt before the first iteration
+	if err := preflight(cfg); err != nil {
+		return err
+	}
 
 	// Get current git branch
-	branch, err := getCurrentBranch()
+	branch, err := getCurrentBranch("")
 	if err != nil {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
@@ -48,8 +91,32 @@ func Run(cfg Config) error {
 		return fmt.Errorf("prompt file not found: %s", cfg.PromptFile)
 	}
 
+	// Summarized once the loop exits, however it exits
+	exitReason := ExitError
+
+	// Work on the session's own branch if requested, so pushes only ever go there. In a
+	// worktree, the branch is checked out there and the main checkout is left alone.
+	if target := sessionBranch(cfg); cfg.Worktree {
+		path := worktreePath(cfg.SessionID)
+		created, err := setupWorktree(path, target)
+		if err != nil {
+			return err
+		}
+		FormatWorktree(cfg.Output, path, target, created)
+		cfg.WorkDir = path
+		branch = target
+		defer func() { cleanupWorktree(cfg.Output, cfg, exitReason) }()
+	} else if target != "" && target != branch {
+		created, err := switchToBranch(target)
+		if err != nil {
+			return fmt.Errorf("failed to switch to branch %s: %w", target, err)
+		}
+		FormatBranchSwitch(cfg.Output, target, branch, created)
+		branch = target
+	}
+
 	// Create plans directory if it doesn't exist
-	if err := os.MkdirAll(PlansDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Join(cfg.WorkDir, PlansDir), 0755); err != nil {
 		return fmt.Errorf("failed to create plans directory: %w", err)
 	}
 
@@ -65,28 +132,120 @@ func Run(cfg Config) error {
 		return err
 	}
 
+	// Record a task started by this session (a resumed task is already recorded)
+	if queue != nil {
+		if current := queue.Current(); current == nil || current.SessionID != cfg.SessionID {
+			if err := queue.Start(filepath.Base(cfg.PromptFile), cfg.PlanFile, cfg.SessionID); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Print configuration
 	FormatHeader(cfg.Output, cfg, branch, provider.Model())
+	if queue != nil {
+		FormatTaskStart(cfg.Output, queue.Current().Task, queue.Remaining())
+	}
 
 	// Create verifier if verification is enabled
 	var verifier *Verifier
 	if cfg.VerifyEnabled {
 		verifier = NewVerifier(cfg.VerifyCommands)
+		verifier.Dir = cfg.WorkDir
 		if !verifier.HasCommands() {
 			fmt.Fprintln(cfg.Output, dimStyle.Render("Warning: No verification commands detected for project type"))
 		}
 	}
 
-	iteration := 0
+	// First Ctrl-C stops after the current iteration, the second aborts the agent
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	interrupts := newInterruptHandler(cfg.Output, cancel)
+	defer interrupts.Stop()
+
+	// Record the session and summarize it however the loop exits
+	tracker, err := newSessionTracker(cfg, resumed, branch, provider.Model())
+	if err != nil {
+		return err
+	}
+	if resumed != nil {
+		fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Resuming session %s after %d iteration(s)", cfg.SessionID, tracker.iterations())))
+	}
+	defer func() {
+		summary, err := tracker.finish(exitReason)
+		if err != nil {
+			fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Warning: Failed to write session summary: %v", err)))
+		}
+		FormatSessionSummary(cfg.Output, *summary)
+	}()
+
+	// Track cost and token usage against the session budget, including earlier runs
+	budget := NewBudget(cfg)
+	if resumed != nil {
+		records, err := LoadSessionIterations(cfg.SessionID)
+		if err != nil {
+			return err
+		}
+		budget.AddRecorded(records)
+	}
+
+	iteration := tracker.iterations()
 	for {
 		iteration++
 
+		// Honor a stop requested during the previous iteration
+		if interrupts.StopRequested() {
+			FormatSessionInterrupted(cfg.Output, iteration-1, false)
+			exitReason = ExitInterrupted
+			break
+		}
+
+		// Stop cleanly once the session budget is used up
+		if reason := budget.Exceeded(); reason != "" {
+			FormatBudgetExceeded(cfg.Output, reason)
+			exitReason = ExitBudget
+			break
+		}
+
 		// Check max iterations
 		if cfg.MaxIterations > 0 && iteration > cfg.MaxIterations {
 			FormatMaxIterations(cfg.Output, cfg.MaxIterations)
+			exitReason = ExitMaxIterations
 			break
 		}
 
+		// Move on to the next task once the current one is complete or capped
+		if queue != nil {
+			if status := queue.Finished(cfg.TaskMaxIterations); status != "" {
+				finished := queue.Current().Task
+				task, ok, err := queue.Finish(status)
+				if err != nil {
+					return err
+				}
+				FormatTaskFinished(cfg.Output, finished, status)
+				if !ok {
+					FormatQueueComplete(cfg.Output, len(queue.State.Completed))
+					exitReason = ExitComplete
+					break
+				}
+
+				// Each task gets a fresh plan (and RLM state)
+				cfg.Resume = ""
+				cfg.PromptFile = taskPath(task)
+				cfg.PlanFile = GeneratePlanPath()
+				if err := runner.Initialize(cfg); err != nil {
+					return err
+				}
+				if err := queue.Start(task, cfg.PlanFile, cfg.SessionID); err != nil {
+					return err
+				}
+				if err := tracker.switchTask(cfg); err != nil {
+					return err
+				}
+				FormatTaskStart(cfg.Output, task, queue.Remaining())
+			}
+		}
+
 		// Show loop banner before iteration (with phase if available)
 		bannerInfo := runner.GetBannerInfo()
 		if bannerInfo.Phase != "" {
@@ -95,25 +254,99 @@ func Run(cfg Config) error {
 			FormatLoopBanner(cfg.Output, iteration)
 		}
 
-		// Run iteration using the mode runner
-		completed, verifyFailed, err := runIteration(cfg, provider, iteration, runner, verifier)
+		// Run iteration using the mode runner, retrying transient failures per the retry policy
+		var outcome *iterationOutcome
+		var retried []*ResultMessage // Results of errored attempts that were retried
+		err := withRetry(ctx, cfg, "iteration", func(attempt int) error {
+			if outcome != nil && outcome.Result != nil {
+				retried = append(retried, outcome.Result)
+			}
+			var err error
+			outcome, err = runIteration(ctx, cfg, providers, iteration, runner, verifier)
+			return err
+		})
+		if outcome == nil {
+			outcome = &iterationOutcome{}
+		}
+		outcome.Result = mergeAttempts(outcome.Result, retried)
+
+		// Whatever the agent used counts against the budget, even if the iteration errored
+		budget.Add(outcome.Result)
+
+		// An errored or aborted iteration counts as failed, with whatever it used recorded
+		outcome.Failed = err != nil
+		if err := tracker.recordIteration(outcome); err != nil {
+			fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Warning: Failed to save session metadata: %v", err)))
+		}
+		if queue != nil {
+			if err := queue.RecordIteration(); err != nil {
+				fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Warning: Failed to save queue state: %v", err)))
+			}
+		}
+		if errors.Is(err, errInterrupted) {
+			FormatSessionInterrupted(cfg.Output, iteration-1, true)
+			exitReason = ExitAborted
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("%s iteration failed: %w", provider.Name(), err)
+			return fmt.Errorf("iteration failed: %w", err)
 		}
-		if completed {
-			FormatSessionComplete(cfg.Output)
-			break
+
+		if warning := budget.Warning(); warning != "" {
+			fmt.Fprintln(cfg.Output, toolActiveStyle.Render(warning))
+		}
+
+		if outcome.Completed {
+			if queue == nil {
+				FormatSessionComplete(cfg.Output)
+				exitReason = ExitComplete
+				break
+			}
+			// Push the task's work as usual, then move on at the start of the next iteration
+			if err := queue.MarkCurrent(TaskComplete); err != nil {
+				return err
+			}
+		}
+
+		// A timed out agent may have left work half done, so never push it
+		if outcome.TimedOut {
+			if cfg.OnTimeout == TimeoutStop {
+				exitReason = ExitTimeout
+				return fmt.Errorf("iteration %d timed out: %s", iteration, outcome.Result.TimeoutReason)
+			}
+			fmt.Fprintln(cfg.Output, dimStyle.Render("Skipping push due to timeout"))
+			continue
 		}
 
 		// Skip push if verification failed
-		if verifyFailed {
+		if outcome.VerifyFailed {
 			fmt.Fprintln(cfg.Output, dimStyle.Render("Skipping push due to verification failure"))
 			continue
 		}
 
+		// Nothing to push if the iteration made no commits
+		if outcome.Empty {
+			FormatEmptyIteration(cfg.Output, iteration, outcome.Uncommitted)
+			continue
+		}
+
 		// Push changes unless --no-push is set
 		if !cfg.NoPush {
-			if err := pushChanges(branch); err != nil {
+			err := withRetry(ctx, cfg, "push", func(attempt int) error {
+				// A retried push was rejected, so catch up with the remote first
+				if attempt > 1 {
+					if err := pullRebase(ctx, cfg.WorkDir, branch); err != nil {
+						return err
+					}
+				}
+				return pushChanges(ctx, cfg.WorkDir, branch)
+			})
+			if errors.Is(context.Cause(ctx), errInterrupted) {
+				FormatSessionInterrupted(cfg.Output, iteration, true)
+				exitReason = ExitAborted
+				return nil
+			}
+			if err != nil {
 				return fmt.Errorf("failed to push changes: %w", err)
 			}
 		}
@@ -122,83 +355,118 @@ func Run(cfg Config) error {
 	return nil
 }
 
-// runIteration runs a single iteration with the mode runner and verification
-func runIteration(cfg Config, provider Provider, iteration int, runner ModeRunner, verifier *Verifier) (completed bool, verifyFailed bool, err error) {
+// iterationOutcome describes how a single iteration ended
+type iterationOutcome struct {
+	Result       *ResultMessage // Final result from the agent (nil if none was received)
+	Completed    bool           // Agent signaled session completion
+	Failed       bool           // Iteration errored or was aborted; Result holds what the agent used before that
+	VerifyRan    bool           // Verification ran this iteration
+	VerifyFailed bool           // Verification ran and failed
+	TimedOut     bool           // Agent was killed by the iteration or idle timeout
+	LogPath      string         // Path of the iteration's JSONL log
+	StartHead    string         // HEAD commit before the agent ran
+	Commit       string         // Commit goralph made for the iteration's changes (auto-commit only)
+	Empty        bool           // Iteration produced no commits where one was expected
+	Uncommitted  bool           // Iteration left changes in the working tree without committing them
+	Rejected     string         // Patch of the changes reverted after failed verification (revert policy only)
+}
+
+// runIteration runs a single iteration with the mode runner and verification.
+// Providers are tried in order: if one fails or reports a rate-limit/overload error,
+// the same iteration is retried with the next provider in the chain.
+func runIteration(ctx context.Context, cfg Config, providers []Provider, iteration int, runner ModeRunner, verifier *Verifier) (*iterationOutcome, error) {
 	// Build prompt using mode runner
 	promptContent, err := runner.BuildPrompt(cfg, iteration)
 	if err != nil {
-		return false, false, err
+		return nil, err
+	}
+
+	// Remember where the iteration started, to tell whether it produced any commits
+	// and, under the revert policy, to restore the working tree if verification fails
+	startHead, _ := getHeadCommit(cfg.WorkDir)
+	var snapshot string
+	if cfg.VerifyEnabled && cfg.OnVerifyFail == VerifyFailRevert {
+		// Without a snapshot a failed iteration can't be reverted, so don't run it at all
+		if snapshot, err = snapshotTree(cfg.WorkDir, projectPathspecs(cfg.WorkDir)...); err != nil {
+			return nil, fmt.Errorf("failed to snapshot working tree for --on-verify-fail revert: %w", err)
+		}
+	}
+	var planBefore []byte
+	if cfg.AutoCommit {
+		planBefore, _ = os.ReadFile(planPath(cfg))
 	}
 
 	// Create logs directory
 	logsDir := filepath.Join(".ralph", "logs")
 	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return false, false, fmt.Errorf("failed to create logs directory: %w", err)
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
 	}
 
-	// Generate timestamped log filename
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	logPath := filepath.Join(logsDir, timestamp+".jsonl")
+	// Generate timestamped log filename, unique per iteration even when iterations finish quickly
+	timestamp := time.Now().Format("2006-01-02_15-04-05.000")
+	logPath := filepath.Join(logsDir, fmt.Sprintf("%s_iter%d.jsonl", timestamp, iteration))
 
 	// Create log file
 	logFile, err := os.Create(logPath)
 	if err != nil {
-		return false, false, fmt.Errorf("failed to create log file: %w", err)
+		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
 	defer logFile.Close()
 
-	// Build the command using the provider
-	cmd, err := provider.BuildCommand(promptContent)
-	if err != nil {
-		return false, false, fmt.Errorf("failed to build command: %w", err)
-	}
+	var resultMsg *ResultMessage
+	var fallbacks []string
+	var failedAttempts []*ResultMessage // Results of attempts abandoned for the next provider
+	var provider Provider
+	for i := range providers {
+		provider = providers[i]
 
-	// Set up stdin with prompt content
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return false, false, fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
+		resultMsg, err = runAgent(ctx, cfg, provider, promptContent, logFile)
 
-	// Capture stdout for parsing
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return false, false, fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
+		// An aborted session must not move on to another provider
+		if errors.Is(err, errInterrupted) {
+			return &iterationOutcome{Result: mergeAttempts(nil, failedAttempts), LogPath: logPath, StartHead: startHead}, err
+		}
 
-	// Connect stderr to terminal
-	cmd.Stderr = os.Stderr
+		// Decide whether this attempt warrants falling back (a timeout does not)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else if resultMsg != nil && resultMsg.AgentError != "" && !resultMsg.TimedOut {
+			reason = resultMsg.AgentError
+		}
+		if reason == "" || i == len(providers)-1 {
+			break
+		}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		return false, false, fmt.Errorf("failed to start %s: %w", provider.Name(), err)
+		if resultMsg != nil {
+			resultMsg.Agent = provider.Name()
+			estimateCost(cfg.Pricing, resultMsg)
+			failedAttempts = append(failedAttempts, resultMsg)
+		}
+		next := providers[i+1]
+		FormatFallback(cfg.Output, provider.Name(), next.Name(), reason)
+		fallbacks = append(fallbacks, fmt.Sprintf("%s -> %s: %s", provider.Name(), next.Name(), reason))
 	}
-
-	// Write prompt to stdin and close
-	if _, err := stdin.Write(promptContent); err != nil {
-		return false, false, fmt.Errorf("failed to write to stdin: %w", err)
+	if resultMsg != nil {
+		resultMsg.Agent = provider.Name()
+		resultMsg.Fallbacks = fallbacks
+		// Estimate cost from token usage for providers that don't report it
+		estimateCost(cfg.Pricing, resultMsg)
 	}
-	stdin.Close()
-
-	// Show progress indicator
-	fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Running %s...", provider.Name())))
-
-	// Track duration externally for providers that don't report it
-	startTime := time.Now()
-
-	// Parse output using the provider and write to log file
-	resultMsg, err := provider.ParseOutput(stdout, cfg.Output, logFile)
+	// The spend of abandoned attempts still counts towards the iteration
+	resultMsg = mergeAttempts(resultMsg, failedAttempts)
+	outcome := &iterationOutcome{Result: resultMsg, LogPath: logPath, StartHead: startHead}
+	// A failed agent's usage is still returned, to be charged to the budget
 	if err != nil {
-		return false, false, fmt.Errorf("failed to parse output: %w", err)
-	}
-
-	// Wait for completion
-	if err := cmd.Wait(); err != nil {
-		return false, false, fmt.Errorf("%s exited with error: %w", provider.Name(), err)
+		return outcome, err
 	}
 
-	// Inject duration if provider didn't supply it
-	if resultMsg != nil && resultMsg.DurationMs == 0 {
-		resultMsg.DurationMs = int(time.Since(startTime).Milliseconds())
+	// Don't act on the output of an agent that was killed mid-run
+	if resultMsg != nil && resultMsg.TimedOut {
+		fmt.Fprintln(cfg.Output)
+		FormatIterationSummary(cfg.Output, *resultMsg)
+		outcome.TimedOut = true
+		return outcome, nil
 	}
 
 	// Display the final result summary
@@ -222,7 +490,11 @@ func runIteration(cfg Config, provider Provider, iteration int, runner ModeRunne
 		fmt.Fprintln(cfg.Output)
 		fmt.Fprintln(cfg.Output, dimStyle.Render("Running verification..."))
 
-		report := verifier.Run(iteration)
+		report := verifier.Run(ctx, iteration)
+		if cause := context.Cause(ctx); errors.Is(cause, errInterrupted) {
+			return outcome, cause
+		}
+		outcome.VerifyRan = true
 
 		// Store verification report using mode runner
 		if err := runner.StoreVerification(report); err != nil {
@@ -233,11 +505,242 @@ func runIteration(cfg Config, provider Provider, iteration int, runner ModeRunne
 			FormatVerificationPassed(cfg.Output)
 		} else {
 			FormatVerificationFailed(cfg.Output, report)
-			return false, true, nil // Continue loop but skip push
+			outcome.VerifyFailed = true // Continue loop but skip push
+		}
+	}
+
+	switch {
+	case outcome.VerifyFailed && cfg.OnVerifyFail == VerifyFailRevert:
+		// Throw the failed changes away, keeping them as a patch
+		outcome.Rejected, err = revertIteration(cfg, iteration, startHead, snapshot)
+		if err != nil {
+			return outcome, fmt.Errorf("failed to revert iteration: %w", err)
+		}
+		FormatReverted(cfg.Output, startHead, outcome.Rejected)
+	case cfg.AutoCommit:
+		// Commit the agent's changes when goralph owns commits
+		outcome.Commit, err = commitIteration(cfg, iteration, resultMsg, string(planBefore))
+		if err != nil {
+			return outcome, err
+		}
+		if outcome.Commit != "" {
+			FormatCommitted(cfg.Output, outcome.Commit)
+		}
+	}
+
+	// Detect an iteration that produced nothing to push. Without --auto-commit, a
+	// --no-push agent is told not to commit, so no commit is expected of it.
+	if !cfg.NoPush || cfg.AutoCommit {
+		head, _ := getHeadCommit(cfg.WorkDir)
+		outcome.Empty = head == startHead
+	}
+	if outcome.Empty {
+		status, _ := getStatusPorcelain(cfg.WorkDir, projectPathspecs(cfg.WorkDir)...)
+		outcome.Uncommitted = status != ""
+	}
+
+	// Check if agent signaled session completion (ignored when verification failed)
+	outcome.Completed = !outcome.VerifyFailed && resultMsg != nil && resultMsg.SessionComplete
+	return outcome, nil
+}
+
+// mergeAttempts adds the spend of abandoned attempts to result. Without a result, the
+// first attempt's result carries the combined spend.
+func mergeAttempts(result *ResultMessage, attempts []*ResultMessage) *ResultMessage {
+	for _, attempt := range attempts {
+		if result == nil {
+			merged := *attempt
+			result = &merged
+			continue
+		}
+		addAttemptSpend(result, attempt)
+	}
+	return result
+}
+
+// addAttemptSpend adds the token usage and cost of an abandoned provider attempt to result
+func addAttemptSpend(result, attempt *ResultMessage) {
+	result.Usage.InputTokens += attempt.Usage.InputTokens
+	result.Usage.OutputTokens += attempt.Usage.OutputTokens
+	result.Usage.CacheCreationInputTokens += attempt.Usage.CacheCreationInputTokens
+	result.Usage.CacheReadInputTokens += attempt.Usage.CacheReadInputTokens
+	if attempt.HasCost {
+		result.TotalCostUSD += attempt.TotalCostUSD
+		result.HasCost = true
+		result.CostEstimated = result.CostEstimated || attempt.CostEstimated
+	}
+}
+
+// killGracePeriod is how long a canceled agent has to exit before it is killed
+const killGracePeriod = 10 * time.Second
+
+// Timeout causes used to tell which watchdog stopped the agent
+var (
+	errIterationTimeout = errors.New("iteration timeout")
+	errIdleTimeout      = errors.New("idle timeout")
+)
+
+// runAgent runs the provider's agent once with the given prompt and parses its output.
+// If the iteration or idle timeout fires, the agent's process group is killed and the
+// partial result is returned with TimedOut set. If ctx is canceled by an interrupt,
+// the signal is forwarded to the agent's process group and the interrupt is returned.
+// If the agent exits with an error, any result it reported is returned with the error,
+// so the usage and cost of the failed run can still be counted.
+func runAgent(ctx context.Context, cfg Config, provider Provider, promptContent []byte, logFile io.Writer) (*ResultMessage, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if cfg.IterationTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, cfg.IterationTimeout, errIterationTimeout)
+		defer cancelTimeout()
+	}
+
+	// Build the command using the provider
+	cmd, err := provider.BuildCommand(ctx, promptContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build command: %w", err)
+	}
+	// Run the agent in the session's worktree, if any
+	cmd.Dir = cfg.WorkDir
+	setProcessGroup(ctx, cmd)
+	// Don't hang on pipes held open by orphaned children once the agent is gone
+	cmd.WaitDelay = killGracePeriod
+
+	// Set up stdin with prompt content, unless the provider already wired stdin
+	// itself (e.g. a command provider that passes the prompt via file)
+	var stdin io.WriteCloser
+	if cmd.Stdin == nil {
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
 		}
 	}
 
-	// Check if agent signaled session completion
-	sessionComplete := resultMsg != nil && resultMsg.SessionComplete
-	return sessionComplete, false, nil
+	// Capture stdout for parsing
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	// Connect stderr to terminal
+	cmd.Stderr = os.Stderr
+
+	// Start the command
+	if err := cmd.Start(); err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errInterrupted) {
+			return nil, cause
+		}
+		return nil, retryable(fmt.Errorf("failed to start %s: %w", provider.Name(), err))
+	}
+
+	// Write prompt to stdin and close
+	if stdin != nil {
+		if _, err := stdin.Write(promptContent); err != nil {
+			return nil, retryable(fmt.Errorf("failed to write to stdin: %w", err))
+		}
+		stdin.Close()
+	}
+
+	// Show progress indicator
+	fmt.Fprintln(cfg.Output, dimStyle.Render(fmt.Sprintf("Running %s...", provider.Name())))
+
+	// If the agent's process group ignores a forwarded signal, kill it after a grace period
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-finished:
+			return
+		}
+		select {
+		case <-time.After(killGracePeriod):
+			signalProcessGroup(cmd, os.Kill)
+		case <-finished:
+		}
+	}()
+
+	// Track duration externally for providers that don't report it
+	startTime := time.Now()
+
+	// Kill the agent if no output arrives within the idle timeout
+	var output io.Reader = stdout
+	if cfg.IdleTimeout > 0 {
+		watchdog := time.AfterFunc(cfg.IdleTimeout, func() { cancel(errIdleTimeout) })
+		defer watchdog.Stop()
+		output = &idleReader{r: stdout, timer: watchdog, timeout: cfg.IdleTimeout}
+	}
+
+	// Parse output using the provider and write to log file
+	resultMsg, parseErr := provider.ParseOutput(output, cfg.Output, logFile)
+
+	// Wait for completion
+	waitErr := cmd.Wait()
+
+	// A user abort or fired watchdog takes precedence over the errors the kill causes
+	if cause := context.Cause(ctx); errors.Is(cause, errInterrupted) {
+		return nil, cause
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, errIterationTimeout) || errors.Is(cause, errIdleTimeout) {
+		reason := timeoutReason(cfg, cause)
+		FormatTimeout(cfg.Output, provider.Name(), reason)
+		logTimeout(logFile, reason)
+		if resultMsg == nil {
+			resultMsg = &ResultMessage{Type: "result", Model: provider.Model()}
+		}
+		resultMsg.IsError = true
+		resultMsg.TimedOut = true
+		resultMsg.TimeoutReason = reason
+		resultMsg.DurationMs = int(time.Since(startTime).Milliseconds())
+		return resultMsg, nil
+	}
+
+	if parseErr != nil {
+		return resultMsg, retryable(fmt.Errorf("failed to parse output: %w", parseErr))
+	}
+	if waitErr != nil {
+		return resultMsg, retryable(fmt.Errorf("%s exited with error: %w", provider.Name(), waitErr))
+	}
+
+	// Inject duration if provider didn't supply it
+	if resultMsg != nil && resultMsg.DurationMs == 0 {
+		resultMsg.DurationMs = int(time.Since(startTime).Milliseconds())
+	}
+
+	return resultMsg, nil
+}
+
+// idleReader resets the idle watchdog timer whenever output arrives
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	n, err := ir.r.Read(p)
+	if n > 0 {
+		ir.timer.Reset(ir.timeout)
+	}
+	return n, err
+}
+
+// timeoutReason describes which timeout stopped the agent
+func timeoutReason(cfg Config, cause error) string {
+	if errors.Is(cause, errIdleTimeout) {
+		return fmt.Sprintf("no output for %s", cfg.IdleTimeout)
+	}
+	return fmt.Sprintf("exceeded iteration timeout of %s", cfg.IterationTimeout)
+}
+
+// logTimeout records a timeout event in the iteration's JSONL log
+func logTimeout(logFile io.Writer, reason string) {
+	if logFile == nil {
+		return
+	}
+	data, err := json.Marshal(map[string]string{"type": "goralph_timeout", "reason": reason})
+	if err != nil {
+		return
+	}
+	logFile.Write(append(data, '\n'))
 }
@@ -0,0 +1,73 @@
+package loop
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+)
+
+// newTestIteration prepares a scaffolded project and a ralph runner for runIteration
+func newTestIteration(t *testing.T) (Config, ModeRunner) {
+	t.Helper()
+	initTestProject(t)
+	if err := os.MkdirAll(PlansDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.SessionID = "s1"
+	cfg.PlanFile = PlansDir + "/implementation_plan_s1.md"
+	cfg.NoPush = true
+	cfg.Output = io.Discard
+	runner := NewRalphRunner()
+	runner.SetOutput(io.Discard)
+	if err := runner.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return cfg, runner
+}
+
+// newTestCommandProvider creates a command provider running a shell script
+func newTestCommandProvider(t *testing.T, name, format, script string) Provider {
+	t.Helper()
+	provider, err := NewCommandProvider(CommandConfig{
+		Name:   name,
+		Args:   []string{"sh", "-c", "cat >/dev/null; " + script},
+		Format: format,
+	}, "")
+	if err != nil {
+		t.Fatalf("NewCommandProvider: %v", err)
+	}
+	return provider
+}
+
+func TestRunIterationCountsSpendOfFailedFallbackAttempt(t *testing.T) {
+	cfg, runner := newTestIteration(t)
+
+	// The first agent reports an overload result with its cost, then exits non-zero
+	failing := newTestCommandProvider(t, "first", CommandFormatClaude,
+		`echo '{"type":"result","subtype":"error_during_execution","is_error":true,"result":"API Error: 529 overloaded","total_cost_usd":0.002,"usage":{"input_tokens":100,"output_tokens":10}}'; exit 1`)
+	fallback := newTestCommandProvider(t, "second", CommandFormatText, "echo done")
+
+	outcome, err := runIteration(context.Background(), cfg, []Provider{failing, fallback}, 1, runner, nil)
+	if err != nil {
+		t.Fatalf("runIteration: %v", err)
+	}
+	result := outcome.Result
+	if result == nil {
+		t.Fatal("expected a result")
+	}
+	if result.Agent != "second" {
+		t.Errorf("Agent = %q, want second", result.Agent)
+	}
+	if len(result.Fallbacks) != 1 {
+		t.Errorf("Fallbacks = %v, want one switch", result.Fallbacks)
+	}
+	if !result.HasCost || result.TotalCostUSD != 0.002 {
+		t.Errorf("cost = %v (HasCost %v), want the failed attempt's 0.002", result.TotalCostUSD, result.HasCost)
+	}
+	if result.Usage.InputTokens != 100 || result.Usage.OutputTokens != 10 {
+		t.Errorf("Usage = %+v, want the failed attempt's tokens", result.Usage)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 // RalphRunner implements ModeRunner for ralph mode
 type RalphRunner struct {
-	output io.Writer
+	output           io.Writer
+	lastVerification *VerificationReport // Most recent report, for the next iteration's prompt
 }
 
 // NewRalphRunner creates a new ralph mode runner
@@ -23,14 +25,40 @@ func (r *RalphRunner) Name() string {
 	return "ralph"
 }
 
-// Initialize sets up ralph mode - resets the implementation plan
+// Initialize sets up ralph mode - resets the implementation plan, or keeps it
+// when resuming a session
 func (r *RalphRunner) Initialize(cfg Config) error {
-	return resetImplementationPlan(cfg.PlanFile)
+	if cfg.Resume != "" {
+		if _, err := os.Stat(planPath(cfg)); err != nil {
+			return fmt.Errorf("failed to resume implementation plan: %w", err)
+		}
+		return nil
+	}
+	return resetImplementationPlan(planPath(cfg))
 }
 
 // BuildPrompt constructs the prompt for the given iteration
 func (r *RalphRunner) BuildPrompt(cfg Config, iteration int) ([]byte, error) {
-	return buildPromptWithPlan(cfg.PromptFile, cfg.PlanFile, iteration, cfg.MaxIterations, cfg.NoPush)
+	lastVerification := previousVerification(r.lastVerification, iteration)
+	promptContent, err := renderPromptFile(cfg, newPromptData(cfg, iteration, "", lastVerification))
+	if err != nil {
+		return nil, err
+	}
+	planContent, err := os.ReadFile(planPath(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read implementation plan: %w", err)
+	}
+	return buildPromptWithPlan(cfg, promptContent, planContent, iteration, lastVerification)
+}
+
+// planPath returns where the plan file is actually read and written: the session
+// plan file (in the session's worktree, if any), or a copy in the scratch directory
+// for dry runs
+func planPath(cfg Config) string {
+	if cfg.ScratchDir != "" {
+		return filepath.Join(cfg.ScratchDir, filepath.Base(cfg.PlanFile))
+	}
+	return filepath.Join(cfg.WorkDir, cfg.PlanFile)
 }
 
 // HandleResult processes the result from an agent iteration
@@ -53,9 +81,9 @@ func (r *RalphRunner) ShouldRunVerification(cfg Config, result *ResultMessage) b
 }
 
 // StoreVerification stores the verification report
-// Ralph mode doesn't persist verification reports
+// Ralph mode keeps the latest report in memory only, for the prompt template
 func (r *RalphRunner) StoreVerification(report VerificationReport) error {
-	// Ralph mode doesn't have persistent state
+	r.lastVerification = &report
 	return nil
 }
 
@@ -87,125 +115,42 @@ func resetImplementationPlan(planFile string) error {
 	return nil
 }
 
-// buildPromptWithPlan reads the prompt file and appends the implementation plan with instructions
-func buildPromptWithPlan(promptFile string, planFile string, iteration int, maxIterations int, noPush bool) ([]byte, error) {
-	// Read the prompt file
-	promptContent, err := os.ReadFile(promptFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt file: %w", err)
-	}
+// buildPromptWithPlan wraps the rendered prompt with the system context block and
+// appends the plan instructions block and the implementation plan. A failed last
+// verification adds its report after the system context, preceded by the fixup
+// block under the fixup policy.
+func buildPromptWithPlan(cfg Config, promptContent []byte, planContent []byte, iteration int, lastVerification *VerificationReport) ([]byte, error) {
+	data := newTemplateData(cfg, iteration)
+	data.VerifyFixup = needsFixup(cfg, lastVerification)
+	data.FailedVerification = failedVerification(cfg, lastVerification)
 
-	// Read the implementation plan
-	planContent, err := os.ReadFile(planFile)
+	systemContext, err := renderBlock(ModeRalph, "system-context", data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read implementation plan: %w", err)
-	}
-
-	// Build iteration display string
-	var iterationStr string
-	if maxIterations > 0 {
-		iterationStr = fmt.Sprintf("%d/%d", iteration, maxIterations)
-	} else {
-		iterationStr = fmt.Sprintf("%d/unlimited", iteration)
+		return nil, err
 	}
-
-	// Build system context based on noPush setting
-	var systemContext string
-	if noPush {
-		systemContext = fmt.Sprintf(`# System Context
-
-You are running in a **goralph agentic loop** - an automated iteration system that manages your context between runs.
-
-**Key facts:**
-- Iteration: %s
-- Each iteration runs with a fresh context window
-- Focus on completing ONE task per iteration
-- After completing a task: update the implementation plan, then exit
-- The loop will automatically restart
-
-**Workflow:**
-1. Study the implementation plan below
-2. Pick the most important uncompleted task
-3. Complete that single task
-4. Update the implementation plan to mark it complete
-5. Exit - the loop handles the rest
-
----
-
-`, iterationStr)
-	} else {
-		systemContext = fmt.Sprintf(`# System Context
-
-You are running in a **goralph agentic loop** - an automated iteration system that manages your context between runs.
-
-**Key facts:**
-- Iteration: %s
-- Each iteration runs with a fresh context window
-- Focus on completing ONE task per iteration
-- After completing a task: update the implementation plan, commit changes, then exit
-- The loop will automatically restart and push your changes
-
-**Workflow:**
-1. Study the implementation plan below
-2. Pick the most important uncompleted task
-3. Complete that single task
-4. Update the implementation plan to mark it complete
-5. Commit with a descriptive message
-6. Exit - the loop handles the rest
-
----
-
-`, iterationStr)
+	if data.VerifyFixup {
+		fixup, err := renderBlock(ModeRalph, "verify-fixup", data)
+		if err != nil {
+			return nil, err
+		}
+		systemContext += "\n\n---\n\n" + fixup
 	}
-
-	// Build task guidance based on whether max iterations is set
-	var taskGuidance string
-	if maxIterations > 0 {
-		taskGuidance = fmt.Sprintf(`If the Tasks section is empty, analyze the project and break the work into approximately %d tasks (one per iteration).`, maxIterations)
-	} else {
-		taskGuidance = `If the Tasks section is empty, analyze the project and add a comprehensive list of implementation tasks.`
+	if data.FailedVerification != nil {
+		report, err := renderBlock(ModeRalph, "verify-failed", data)
+		if err != nil {
+			return nil, err
+		}
+		systemContext += "\n\n---\n\n" + report
 	}
-
-	// Build completion steps based on noPush setting
-	var completionSteps string
-	if noPush {
-		completionSteps = `Complete ONE task, then:
-1. Update ` + "`" + planFile + "`" + ` to mark the task as completed (move to Completed section)
-2. Exit`
-	} else {
-		completionSteps = `Complete ONE task, then:
-1. Update ` + "`" + planFile + "`" + ` to mark the task as completed (move to Completed section)
-2. Commit your changes with a descriptive message
-3. Exit`
+	instructions, err := renderBlock(ModeRalph, "plan-instructions", data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build instructions to append
-	instructions := `
----
-
-# Implementation Plan Instructions
-
-Study the implementation plan below. Pick the most important uncompleted task.
-
-` + taskGuidance + `
-
-` + completionSteps + `
-
-**Completion Promise:**
-When ALL tasks in the plan are complete and there is no more work to do, output this exact line:
-` + "`" + CompletionPromise + "`" + `
-This signals the loop to exit gracefully instead of continuing to the next iteration.
-
-The loop will automatically restart with a fresh context window.
-
----
-
-# Current Implementation Plan
-
-` + "```markdown\n" + string(planContent) + "\n```"
-
 	// Combine system context + prompt + instructions + plan
-	combined := append([]byte(systemContext), promptContent...)
-	combined = append(combined, []byte(instructions)...)
+	combined := []byte(systemContext + "\n\n---\n\n")
+	combined = append(combined, promptContent...)
+	combined = append(combined, "\n---\n\n"+instructions+"\n\n---\n\n# Current Implementation Plan\n\n"...)
+	combined = append(combined, "```markdown\n"+string(planContent)+"\n```"...)
 	return combined, nil
 }
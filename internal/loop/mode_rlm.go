@@ -7,9 +7,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 // RLMRunner implements ModeRunner for RLM mode
@@ -32,10 +31,17 @@ func (r *RLMRunner) Name() string {
 	return "rlm"
 }
 
-// Initialize sets up RLM mode - creates state manager and initializes session
+// Initialize sets up RLM mode - creates state manager and initializes session,
+// or reuses the existing state when resuming a session
 func (r *RLMRunner) Initialize(cfg Config) error {
-	r.stateManager = NewStateManager(StateDir)
-	if _, err := r.stateManager.InitSession(); err != nil {
+	r.stateManager = NewStateManager(stateDir(cfg))
+	if cfg.Resume != "" {
+		if _, err := r.stateManager.ResumeSession(cfg.SessionID); err != nil {
+			return fmt.Errorf("failed to resume RLM session: %w", err)
+		}
+		return nil
+	}
+	if _, err := r.stateManager.InitSession(cfg.SessionID); err != nil {
 		return fmt.Errorf("failed to initialize RLM session: %w", err)
 	}
 	return nil
@@ -122,12 +128,6 @@ func (r *RLMRunner) SetOutput(w io.Writer) {
 
 // buildRLMPrompt builds a prompt with RLM context and phase-specific guidance
 func (r *RLMRunner) buildRLMPrompt(cfg Config, iteration int) ([]byte, error) {
-	// Read the prompt file
-	promptContent, err := os.ReadFile(cfg.PromptFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt file: %w", err)
-	}
-
 	// Load session state
 	session, err := r.stateManager.LoadSession()
 	if err != nil {
@@ -141,8 +141,13 @@ func (r *RLMRunner) buildRLMPrompt(cfg Config, iteration int) ([]byte, error) {
 		phase = PhasePlan // Default to plan on error
 	}
 
-	// Get phase-specific guidance
-	phaseGuidance := router.GetPhaseGuidance(phase)
+	// Render the prompt file template
+	latest, _ := r.stateManager.GetLatestVerification()
+	lastVerification := previousVerification(latest, iteration)
+	promptContent, err := renderPromptFile(cfg, newPromptData(cfg, iteration, string(phase), lastVerification))
+	if err != nil {
+		return nil, err
+	}
 
 	// Load context manifest for inclusion
 	context, err := r.stateManager.GetContext()
@@ -154,54 +159,43 @@ func (r *RLMRunner) buildRLMPrompt(cfg Config, iteration int) ([]byte, error) {
 	recentHistory, _ := r.stateManager.GetRecentHistory(5)
 	historySection := formatHistorySection(recentHistory)
 
-	// Build iteration display string
-	var iterationStr string
-	if cfg.MaxIterations > 0 {
-		iterationStr = fmt.Sprintf("%d/%d", iteration, cfg.MaxIterations)
-	} else {
-		iterationStr = fmt.Sprintf("%d/unlimited", iteration)
-	}
-
-	// Build RLM principle #3 based on noPush setting
-	var rlmPrinciple3 string
-	if cfg.NoPush {
-		rlmPrinciple3 = "3. **One task per iteration**: Complete ONE task, implement changes, update state, exit."
-	} else {
-		rlmPrinciple3 = "3. **One task per iteration**: Complete ONE task, update state, commit, exit."
+	// Render the prompt blocks, preferring overrides in .ralph/templates/rlm/
+	data := newTemplateData(cfg, iteration)
+	data.SessionID = session.SessionID
+	data.Phase = phase
+	data.PhaseName = PhaseDisplayName(phase)
+	data.Depth = session.Depth
+	data.MaxDepth = r.maxDepth
+	data.VerifyFixup = needsFixup(cfg, lastVerification)
+	data.FailedVerification = failedVerification(cfg, lastVerification)
+	names := []string{"system-context", phaseBlock(phase), "state-files", "markers"}
+	if data.VerifyFixup {
+		names = append(names, "verify-fixup")
+	}
+	if data.FailedVerification != nil {
+		names = append(names, "verify-failed")
+	}
+	blocks := make(map[string]string)
+	for _, block := range names {
+		text, err := renderBlock(ModeRLM, block, data)
+		if err != nil {
+			return nil, err
+		}
+		blocks[block] = text
 	}
 
 	// Build system context with RLM principles
-	systemContext := fmt.Sprintf(`# System Context
-
-You are running in a **goralph RLM-enhanced agentic loop**.
-
-## RLM Principles
-
-1. **Context is external**: The full codebase is NOT in your context. Use tools to explore.
-2. **State persists**: Discoveries are stored in %s. Reference previous findings.
-%s
-4. **Verify before commit**: Run relevant checks before marking complete.
-
-## Session Info
+	systemContext := blocks["system-context"] + "\n\n---\n\n"
 
-- **Iteration:** %s
-- **Session ID:** %s
-- **Current Phase:** %s (%s)
-- **Depth:** %d/%d
-
-## Available State Files
-
-- Context manifest: %s/context.json
-- Previous searches: %s/search/
-- Narrowed sets: %s/narrow/
-- History: %s/history.jsonl
-- Verification reports: %s/verification/
-
----
+	// Ask for the kept changes of a failed verification to be fixed first
+	if data.VerifyFixup {
+		systemContext += blocks["verify-fixup"] + "\n\n---\n\n"
+	}
 
-`, StateDir, rlmPrinciple3, iterationStr, session.SessionID, phase, PhaseDisplayName(phase),
-		session.Depth, r.maxDepth,
-		StateDir, StateDir, StateDir, StateDir, StateDir)
+	// Show the failed checks of the last verification
+	if data.FailedVerification != nil {
+		systemContext += blocks["verify-failed"] + "\n\n---\n\n"
+	}
 
 	// Add context summary if available
 	contextSection := formatContextSection(context)
@@ -215,13 +209,13 @@ You are running in a **goralph RLM-enhanced agentic loop**.
 	}
 
 	// Add phase-specific guidance
-	systemContext += phaseGuidance + "\n\n---\n\n"
+	systemContext += blocks[phaseBlock(phase)] + "\n\n---\n\n"
 
 	// Add state file conventions
-	systemContext += stateFileInstructions + "\n\n---\n\n"
+	systemContext += blocks["state-files"] + "\n\n---\n\n"
 
 	// Add RLM marker instructions
-	systemContext += getRLMMarkerInstructions(cfg.NoPush) + "\n---\n\n"
+	systemContext += blocks["markers"] + "\n---\n\n"
 
 	// Add the user's task
 	systemContext += "# Task\n\n"
@@ -296,25 +290,6 @@ func formatHistorySection(entries []HistoryEntry) string {
 	return section
 }
 
-// getRLMMarkerInstructions returns instructions for RLM output markers based on noPush setting
-func getRLMMarkerInstructions(noPush bool) string {
-	base := "## RLM Output Markers\n\n" +
-		"Use these markers to communicate state transitions:\n\n" +
-		"1. **Phase transition:** Signal which phase to enter next:\n" +
-		"   `<rlm:phase>PHASE_NAME</rlm:phase>`\n" +
-		"   Valid phases: PLAN, SEARCH, NARROW, ACT, VERIFY\n\n" +
-		"2. **Verification passed:** Signal that verification succeeded:\n" +
-		"   `<rlm:verified>true</rlm:verified>`\n\n" +
-		"3. **Session complete:** Signal all tasks are done:\n" +
-		"   `<promise>COMPLETE</promise>`"
-
-	if !noPush {
-		base += "\n\n**Important:** Always commit your changes before signaling completion."
-	}
-
-	return base
-}
-
 // stateFileInstructions provides guidance for writing state files
 const stateFileInstructions = `## State File Conventions
 
@@ -374,6 +349,15 @@ const (
 // StateDir is the directory for RLM state files
 const StateDir = ".ralph/state"
 
+// stateDir returns where RLM state is actually kept: StateDir (in the session's
+// worktree, if any), or a directory in the scratch directory for dry runs
+func stateDir(cfg Config) string {
+	if cfg.ScratchDir != "" {
+		return filepath.Join(cfg.ScratchDir, "state")
+	}
+	return filepath.Join(cfg.WorkDir, StateDir)
+}
+
 // SessionState represents the current RLM session state
 type SessionState struct {
 	SessionID   string    `json:"session_id"`
@@ -488,7 +472,7 @@ func NewStateManager(baseDir string) *StateManager {
 }
 
 // InitSession initializes a new RLM session, cleaning up any previous state
-func (sm *StateManager) InitSession() (*SessionState, error) {
+func (sm *StateManager) InitSession(sessionID string) (*SessionState, error) {
 	// Clean up previous state
 	if err := os.RemoveAll(sm.baseDir); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("failed to clean previous state: %w", err)
@@ -511,7 +495,7 @@ func (sm *StateManager) InitSession() (*SessionState, error) {
 	// Create initial session state
 	now := time.Now()
 	state := &SessionState{
-		SessionID:   uuid.New().String(),
+		SessionID:   sessionID,
 		Iteration:   0,
 		Depth:       0,
 		Phase:       PhasePlan,
@@ -538,6 +522,19 @@ func (sm *StateManager) InitSession() (*SessionState, error) {
 	return state, nil
 }
 
+// ResumeSession loads the existing state of an RLM session, checking that the
+// state directory still belongs to that session
+func (sm *StateManager) ResumeSession(sessionID string) (*SessionState, error) {
+	state, err := sm.LoadSession()
+	if err != nil {
+		return nil, err
+	}
+	if state.SessionID != sessionID {
+		return nil, fmt.Errorf("state in %s belongs to session %s", sm.baseDir, state.SessionID)
+	}
+	return state, nil
+}
+
 // LoadSession loads the current session state
 func (sm *StateManager) LoadSession() (*SessionState, error) {
 	path := filepath.Join(sm.baseDir, "session.json")
@@ -796,7 +793,17 @@ func (pr *PhaseRouter) InferPhase() (Phase, error) {
 	}
 }
 
-// GetPhaseGuidance returns phase-specific instructions for the agent
+// phaseBlock returns the name of a phase's guidance prompt block
+func phaseBlock(phase Phase) string {
+	switch phase {
+	case PhasePlan, PhaseSearch, PhaseNarrow, PhaseAct, PhaseVerify:
+		return "phase-" + strings.ToLower(string(phase))
+	default:
+		return "phase-plan"
+	}
+}
+
+// GetPhaseGuidance returns the built-in phase-specific instruction template for the agent
 func (pr *PhaseRouter) GetPhaseGuidance(phase Phase) string {
 	switch phase {
 	case PhasePlan:
@@ -934,7 +941,7 @@ const verifyPhaseGuidance = `## Phase: VERIFY
 **If verification passes:**
 1. Record success in verification file
 2. Signal verification success with: ` + "`" + `<rlm:verified>true</rlm:verified>` + "`" + `
-3. Commit your changes
+3. {{if .AutoCommit}}Leave your changes uncommitted (the loop commits them){{else}}Commit your changes{{end}}
 4. If all tasks complete: ` + "`" + `<promise>COMPLETE</promise>` + "`" + `
 5. Otherwise signal next search: ` + "`" + `<rlm:phase>SEARCH</rlm:phase>` + "`" + `
 
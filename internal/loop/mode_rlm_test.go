@@ -0,0 +1,51 @@
+package loop
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRLMPromptOnlyShowsPreviousVerificationFailure(t *testing.T) {
+	initTestProject(t)
+	cfg := DefaultConfig()
+	cfg.Mode = ModeRLM
+	cfg.SessionID = "s1"
+	runner := NewRLMRunner(cfg.RLMMaxDepth)
+	runner.SetOutput(io.Discard)
+	if err := runner.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	failed := VerificationReport{Iteration: 1, Checks: []VerificationCheck{
+		{Name: "test", Command: "go test ./...", Output: "FAIL: TestSomething"},
+	}}
+	if err := runner.StoreVerification(failed); err != nil {
+		t.Fatalf("StoreVerification: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		iteration int
+		passed    *VerificationReport // stored before building the prompt
+		want      bool
+	}{
+		{"after the failure", 2, nil, true},
+		{"a later unverified iteration", 3, nil, false},
+		{"after a passing verification", 3, &VerificationReport{Iteration: 2, Passed: true}, false},
+	}
+	for _, tt := range tests {
+		if tt.passed != nil {
+			if err := runner.StoreVerification(*tt.passed); err != nil {
+				t.Fatalf("StoreVerification: %v", err)
+			}
+		}
+		prompt, err := runner.BuildPrompt(cfg, tt.iteration)
+		if err != nil {
+			t.Fatalf("%s: BuildPrompt: %v", tt.name, err)
+		}
+		if got := strings.Contains(string(prompt), "FAIL: TestSomething"); got != tt.want {
+			t.Errorf("%s: prompt shows the iteration 1 failure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
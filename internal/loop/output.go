@@ -3,6 +3,9 @@ package loop
 import (
 	"fmt"
 	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -70,6 +73,18 @@ func FormatHeader(w io.Writer, cfg Config, branch string, model string) {
 	if agentName == "" {
 		agentName = "claude"
 	}
+	if cfg.Agent == AgentCommand && cfg.Command.Name != "" {
+		agentName = cfg.Command.Name
+	}
+	agentLine := titleStyle.Render(agentName)
+	for _, fallback := range cfg.FallbackAgents {
+		agentLine += dimStyle.Render(" -> ") + string(fallback)
+	}
+
+	// Model is empty when the agent CLI picks its own default
+	if model == "" {
+		model = dimStyle.Render("default")
+	}
 
 	// Build mode indicator
 	var modeLine string
@@ -82,13 +97,44 @@ func FormatHeader(w io.Writer, cfg Config, branch string, model string) {
 		modeLine = fmt.Sprintf("\n%s %s", dimStyle.Render("Mode:"), successStyle.Render("Verify"))
 	}
 
-	content := fmt.Sprintf("%s %s\n%s %s\n%s %s\n%s %s%s%s",
-		dimStyle.Render("Agent:"), titleStyle.Render(agentName),
+	// Show session budget limits if set
+	var budgetLine string
+	if cfg.MaxCost > 0 || cfg.MaxTokens > 0 {
+		var limits []string
+		if cfg.MaxCost > 0 {
+			limits = append(limits, fmt.Sprintf("$%.4f", cfg.MaxCost))
+		}
+		if cfg.MaxTokens > 0 {
+			limits = append(limits, formatNumber(cfg.MaxTokens)+" tokens")
+		}
+		budgetLine = fmt.Sprintf("\n%s %s", dimStyle.Render("Budget:"), strings.Join(limits, ", "))
+	}
+
+	// Show push behavior only when it deviates from the default
+	var pushLine string
+	if cfg.NoPush {
+		pushLine = fmt.Sprintf("\n%s %s", dimStyle.Render("Push:"), "disabled")
+	}
+
+	// Show who commits only when goralph does
+	if cfg.AutoCommit {
+		pushLine += fmt.Sprintf("\n%s %s", dimStyle.Render("Commit:"), "auto")
+	}
+
+	var profileLine string
+	if cfg.Profile != "" {
+		profileLine = fmt.Sprintf("%s %s\n", dimStyle.Render("Profile:"), titleStyle.Render(cfg.Profile))
+	}
+
+	content := fmt.Sprintf("%s%s %s\n%s %s\n%s %s\n%s %s%s%s%s",
+		profileLine,
+		dimStyle.Render("Agent:"), agentLine,
 		dimStyle.Render("Model:"), model,
 		dimStyle.Render("Prompt:"), cfg.PromptFile,
 		dimStyle.Render("Branch:"), successStyle.Render(branch),
 		maxLine,
 		modeLine,
+		budgetLine+pushLine,
 	)
 
 	fmt.Fprintln(w, headerBoxStyle.Render(content))
@@ -104,7 +150,9 @@ func FormatIterationSummary(w io.Writer, result ResultMessage) {
 
 	// Build status indicator
 	var statusIndicator string
-	if result.IsError {
+	if result.TimedOut {
+		statusIndicator = errorStyle.Render("TIMEOUT")
+	} else if result.IsError {
 		statusIndicator = errorStyle.Render("ERROR")
 	} else {
 		statusIndicator = successStyle.Render("OK")
@@ -114,6 +162,9 @@ func FormatIterationSummary(w io.Writer, result ResultMessage) {
 	var costStr string
 	if result.HasCost {
 		costStr = fmt.Sprintf("$%.4f", result.TotalCostUSD)
+		if result.CostEstimated {
+			costStr += dimStyle.Render(" (est.)")
+		}
 	} else {
 		costStr = dimStyle.Render("N/A")
 	}
@@ -131,6 +182,18 @@ func FormatIterationSummary(w io.Writer, result ResultMessage) {
 		statusIndicator,
 	)
 
+	if result.TimedOut {
+		line2 += "\n" + fmt.Sprintf("%s %s", dimStyle.Render("Timeout:"), result.TimeoutReason)
+	}
+
+	// Record provider switches made during the iteration
+	for _, fallback := range result.Fallbacks {
+		line2 += "\n" + fmt.Sprintf("%s %s", dimStyle.Render("Fallback:"), fallback)
+	}
+	if len(result.Fallbacks) > 0 {
+		line2 += "\n" + fmt.Sprintf("%s %s", dimStyle.Render("Completed by:"), result.Agent)
+	}
+
 	// Note: Result text is not printed here because it's streamed in real-time
 	// during parseClaudeOutput via processAssistantMessage
 
@@ -139,6 +202,66 @@ func FormatIterationSummary(w io.Writer, result ResultMessage) {
 	fmt.Fprintln(w, boxStyle.Render(content))
 }
 
+// FormatFallback renders a notice that the iteration is being retried with the next provider
+func FormatFallback(w io.Writer, from, to, reason string) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, errorStyle.Render(fmt.Sprintf("✗ %s failed: %s", from, reason)))
+	fmt.Fprintln(w, toolActiveStyle.Render(fmt.Sprintf("↻ Retrying iteration with %s", to)))
+}
+
+// FormatTimeout renders a notice that the agent was killed by a timeout
+func FormatTimeout(w io.Writer, agent, reason string) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, errorStyle.Render(fmt.Sprintf("✗ %s killed: %s", agent, reason)))
+}
+
+// FormatBranchSwitch renders a notice that the loop switched to the session's branch
+func FormatBranchSwitch(w io.Writer, branch, from string, created bool) {
+	if from == "" {
+		from = "detached HEAD"
+	}
+	if created {
+		fmt.Fprintln(w, successStyle.Render("✓")+fmt.Sprintf(" Created branch %s from %s", branch, from))
+	} else {
+		fmt.Fprintln(w, successStyle.Render("✓")+fmt.Sprintf(" Switched to branch %s", branch))
+	}
+}
+
+// FormatWorktree renders a notice about the worktree the agent runs in
+func FormatWorktree(w io.Writer, path, branch string, created bool) {
+	if created {
+		fmt.Fprintln(w, successStyle.Render("✓")+fmt.Sprintf(" Created worktree %s on branch %s", path, branch))
+	} else {
+		fmt.Fprintln(w, successStyle.Render("✓")+fmt.Sprintf(" Using worktree %s on branch %s", path, branch))
+	}
+}
+
+// FormatReverted renders a notice that a failed iteration's changes were reverted
+func FormatReverted(w io.Writer, commit, patch string) {
+	fmt.Fprintln(w, toolActiveStyle.Render(fmt.Sprintf("↺ Reverted to %s; rejected changes saved to %s", shortHash(commit), patch)))
+}
+
+// FormatCommitted renders a notice that goralph committed the iteration's changes
+func FormatCommitted(w io.Writer, commit string) {
+	fmt.Fprintln(w, successStyle.Render("✓")+" Committed changes as "+shortHash(commit))
+}
+
+// FormatEmptyIteration renders a warning that an iteration produced no commits
+func FormatEmptyIteration(w io.Writer, iteration int, uncommitted bool) {
+	msg := fmt.Sprintf("Empty iteration: iteration %d produced no commits", iteration)
+	if uncommitted {
+		msg = fmt.Sprintf("Empty iteration: iteration %d left uncommitted changes without committing them (use --auto-commit to have goralph commit)", iteration)
+	}
+	fmt.Fprintln(w, toolActiveStyle.Render(msg))
+}
+
+// FormatRetry renders a notice that a failed step will be retried after a delay
+func FormatRetry(w io.Writer, label string, attempt, maxAttempts int, delay time.Duration, err error) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, errorStyle.Render(fmt.Sprintf("✗ %s failed: %v", label, err)))
+	fmt.Fprintln(w, toolActiveStyle.Render(fmt.Sprintf("↻ Retrying %s (attempt %d/%d) in %s", label, attempt, maxAttempts, delay.Round(time.Second))))
+}
+
 // FormatLoopBanner renders the loop iteration banner
 func FormatLoopBanner(w io.Writer, iteration int) {
 	banner := fmt.Sprintf(" LOOP %d ", iteration)
@@ -161,6 +284,200 @@ func FormatSessionComplete(w io.Writer) {
 	fmt.Fprintln(w, boxStyle.Render(content))
 }
 
+// FormatBudgetExceeded renders the budget exceeded message
+func FormatBudgetExceeded(w io.Writer, reason string) {
+	content := errorStyle.Render("Budget Exceeded") + "\n" + dimStyle.Render("Stopping session: "+reason)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, boxStyle.Render(content))
+}
+
+// FormatTaskStart renders the banner for a task from the task queue
+func FormatTaskStart(w io.Writer, task string, remaining int) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, toolActiveStyle.Render(fmt.Sprintf("▶ Task %s (%d remaining)", task, remaining)))
+}
+
+// FormatTaskFinished renders the message for a task leaving the task queue
+func FormatTaskFinished(w io.Writer, task, status string) {
+	msg := fmt.Sprintf("✓ Task %s complete", task)
+	if status == TaskCapped {
+		msg = fmt.Sprintf("Task %s reached its iteration limit", task)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, dimStyle.Render(msg))
+}
+
+// FormatQueueComplete renders the message for an exhausted task queue
+func FormatQueueComplete(w io.Writer, tasks int) {
+	content := successStyle.Render("Task Queue Complete") + "\n" +
+		dimStyle.Render(fmt.Sprintf("Worked through %d task(s) in %s", tasks, TasksDir))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, boxStyle.Render(content))
+}
+
+// FormatSessionInterrupted renders the session interrupted message
+func FormatSessionInterrupted(w io.Writer, iterations int, aborted bool) {
+	detail := fmt.Sprintf("Stopped at user request after %d completed iteration(s)", iterations)
+	if aborted {
+		detail = fmt.Sprintf("Agent aborted mid-iteration after %d completed iteration(s); changes were not pushed", iterations)
+	}
+	content := errorStyle.Render("Session Interrupted") + "\n" + dimStyle.Render(detail)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, boxStyle.Render(content))
+}
+
+// FormatSessionSummary renders the cumulative session summary box
+func FormatSessionSummary(w io.Writer, summary SessionSummary) {
+	wallTime := time.Duration(summary.WallTimeMs) * time.Millisecond
+
+	// Summaries written before has_cost existed only have the total to go by
+	costStr := "N/A"
+	if summary.HasCost || summary.CostUSD > 0 {
+		costStr = fmt.Sprintf("$%.4f", summary.CostUSD)
+		if summary.CostEstimated {
+			costStr += dimStyle.Render(" (est.)")
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s %s  %s %s  %s %s",
+			dimStyle.Render("Session:"), summary.SessionID,
+			dimStyle.Render("Exit:"), summary.ExitReason,
+			dimStyle.Render("Wall time:"), wallTime.Round(time.Second),
+		),
+		fmt.Sprintf("%s %d %s",
+			dimStyle.Render("Iterations:"), summary.Iterations,
+			dimStyle.Render(fmt.Sprintf("(%d ok, %d failed, %d skipped, %d empty)", summary.Succeeded, summary.Failed, summary.Skipped, summary.Empty)),
+		),
+		fmt.Sprintf("%s %s in %s %s out  %s %s",
+			dimStyle.Render("Tokens:"), formatNumber(summary.InputTokens),
+			dimStyle.Render("->"), formatNumber(summary.OutputTokens),
+			dimStyle.Render("Cost:"), costStr,
+		),
+		fmt.Sprintf("%s %d  %s %d",
+			dimStyle.Render("Commits:"), summary.Commits,
+			dimStyle.Render("Files changed:"), summary.FilesChanged,
+		),
+	}
+	if summary.VerificationRuns > 0 {
+		lines = append(lines, fmt.Sprintf("%s %d/%d passed",
+			dimStyle.Render("Verification:"), summary.VerificationPassed, summary.VerificationRuns))
+	}
+	if summary.TasksCompleted+summary.TasksRemaining > 0 {
+		lines = append(lines, fmt.Sprintf("%s %d completed, %d remaining",
+			dimStyle.Render("Plan tasks:"), summary.TasksCompleted, summary.TasksRemaining))
+	}
+
+	content := titleStyle.Render("Session Summary") + "\n" + strings.Join(lines, "\n")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, boxStyle.Render(content))
+}
+
+// FormatSessionList renders a table of recorded sessions
+func FormatSessionList(w io.Writer, sessions []SessionMeta) {
+	if len(sessions) == 0 {
+		fmt.Fprintln(w, dimStyle.Render("No sessions recorded in "+SessionsDir))
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tSTARTED\tSTATUS\tMODE\tAGENT\tBRANCH\tITERATIONS\tCOST")
+	for _, s := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.SessionID, s.StartedAt.Local().Format("2006-01-02 15:04"), s.Status,
+			s.Mode, s.Agent, s.Branch, s.Iterations, formatCost(s.CostUSD, s.CostEstimated))
+	}
+	tw.Flush()
+}
+
+// FormatSessionDetail renders a session's metadata, iteration records and plan
+func FormatSessionDetail(w io.Writer, meta SessionMeta, records []IterationRecord, plan string) {
+	ended := "-"
+	if meta.EndedAt != nil {
+		ended = meta.EndedAt.Local().Format(time.DateTime)
+	}
+	model := meta.Model
+	if model == "" {
+		model = "default"
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s %s", dimStyle.Render("Status:"), meta.Status),
+		fmt.Sprintf("%s %s  %s %s", dimStyle.Render("Started:"), meta.StartedAt.Local().Format(time.DateTime), dimStyle.Render("Ended:"), ended),
+		fmt.Sprintf("%s %s  %s %s  %s %s", dimStyle.Render("Agent:"), meta.Agent, dimStyle.Render("Model:"), model, dimStyle.Render("Mode:"), meta.Mode),
+		fmt.Sprintf("%s %s  %s %s", dimStyle.Render("Branch:"), meta.Branch, dimStyle.Render("Start commit:"), shortHash(meta.StartHead)),
+		fmt.Sprintf("%s %d  %s %s", dimStyle.Render("Iterations:"), meta.Iterations, dimStyle.Render("Cost:"), formatCost(meta.CostUSD, meta.CostEstimated)),
+		fmt.Sprintf("%s %s", dimStyle.Render("Plan:"), meta.PlanFile),
+	}
+	if meta.Worktree != "" {
+		lines = append(lines, fmt.Sprintf("%s %s  %s %s", dimStyle.Render("Worktree:"), meta.Worktree, dimStyle.Render("Cleanup:"), meta.WorktreeCleanup))
+	}
+	fmt.Fprintln(w, headerBoxStyle.Render(titleStyle.Render("Session "+meta.SessionID)+"\n"+strings.Join(lines, "\n")))
+
+	if len(records) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, titleStyle.Render("Iterations"))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tSTATUS\tAGENT\tDURATION\tTOKENS IN/OUT\tCOST\tHEAD\tLOG")
+		for _, r := range records {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%.1fs\t%s/%s\t$%.4f\t%s\t%s\n",
+				r.Iteration, r.Status, r.Agent, float64(r.DurationMs)/1000.0,
+				formatNumber(r.InputTokens), formatNumber(r.OutputTokens), r.CostUSD,
+				shortHash(r.Head), r.LogFile)
+		}
+		tw.Flush()
+	}
+
+	if plan != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, titleStyle.Render("Plan"))
+		fmt.Fprintln(w, strings.TrimRight(plan, "\n"))
+	}
+}
+
+// formatCost formats a USD cost, marking estimates
+func formatCost(cost float64, estimated bool) string {
+	s := fmt.Sprintf("$%.4f", cost)
+	if estimated {
+		s += " (est.)"
+	}
+	return s
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	if hash == "" {
+		return "-"
+	}
+	return hash
+}
+
+// FormatDiagnostics renders one line per diagnostic check with its status
+func FormatDiagnostics(w io.Writer, checks []DiagnosticCheck) {
+	for _, c := range checks {
+		var status string
+		switch c.Status {
+		case CheckPass:
+			status = successStyle.Render("PASS")
+		case CheckWarn:
+			status = toolActiveStyle.Render("WARN")
+		default:
+			status = errorStyle.Render("FAIL")
+		}
+		fmt.Fprintf(w, "%s  %-20s %s\n", status, c.Name, dimStyle.Render(c.Detail))
+	}
+}
+
+// FormatPromptStats renders the size and approximate token count of a rendered prompt
+func FormatPromptStats(w io.Writer, mode Mode, iteration int, prompt []byte) {
+	msg := fmt.Sprintf("Rendered %s prompt for iteration %d: %s bytes, ~%s tokens",
+		mode, iteration, formatNumber(len(prompt)), formatNumber(EstimateTokens(prompt)))
+	fmt.Fprintln(w, dimStyle.Render(msg))
+}
+
 // formatNumber adds commas to large numbers for readability
 func formatNumber(n int) string {
 	if n < 1000 {
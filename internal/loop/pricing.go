@@ -0,0 +1,84 @@
+package loop
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ModelPricing holds per-million-token rates in USD for a model
+type ModelPricing struct {
+	Input       float64 `yaml:"input"`
+	CachedInput float64 `yaml:"cached_input"`
+	Output      float64 `yaml:"output"`
+}
+
+// DefaultPricing returns the built-in pricing table, keyed by model name.
+// Agent names ("codex", "gemini") are fallback keys used when the model is unknown,
+// priced at that CLI's default model. Config files can override or extend the table.
+func DefaultPricing() map[string]ModelPricing {
+	return map[string]ModelPricing{
+		"gpt-5":             {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5-codex":       {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5-mini":        {Input: 0.25, CachedInput: 0.025, Output: 2},
+		"gpt-5-nano":        {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+		"codex-mini-latest": {Input: 1.5, CachedInput: 0.375, Output: 6},
+		"o4-mini":           {Input: 1.1, CachedInput: 0.275, Output: 4.4},
+		"o3":                {Input: 2, CachedInput: 0.5, Output: 8},
+		"gemini-2.5-pro":    {Input: 1.25, CachedInput: 0.31, Output: 10},
+		"gemini-2.5-flash":  {Input: 0.3, CachedInput: 0.075, Output: 2.5},
+		"codex":             {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gemini":            {Input: 1.25, CachedInput: 0.31, Output: 10},
+	}
+}
+
+// datedSuffix matches the date suffix of a model snapshot, e.g. "-2025-08-07" or "-20250514"
+var datedSuffix = regexp.MustCompile(`^-\d{4}`)
+
+// lookupPricing finds the pricing for a model by exact name or as a dated snapshot of
+// a known model (e.g. "o3" for "o3-2025-04-16"). Other models have unknown pricing,
+// rather than the rate of a model whose name they happen to start with. The agent name
+// is used only when the model isn't known at all.
+func lookupPricing(table map[string]ModelPricing, model, agent string) (ModelPricing, bool) {
+	if model == "" {
+		pricing, ok := table[agent]
+		return pricing, ok
+	}
+	if pricing, ok := table[model]; ok {
+		return pricing, true
+	}
+	for name, pricing := range table {
+		if rest, ok := strings.CutPrefix(model, name); ok && datedSuffix.MatchString(rest) {
+			return pricing, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// estimateCost fills in an estimated TotalCostUSD for results whose provider doesn't
+// report cost. Input tokens are assumed to include the cached input tokens, as reported
+// by Codex and Gemini.
+func estimateCost(table map[string]ModelPricing, result *ResultMessage) {
+	if result == nil || result.HasCost {
+		return
+	}
+	usage := result.Usage
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return
+	}
+	pricing, ok := lookupPricing(table, result.Model, result.Agent)
+	if !ok {
+		return
+	}
+
+	uncached := usage.InputTokens - usage.CacheReadInputTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	cost := float64(uncached)*pricing.Input +
+		float64(usage.CacheReadInputTokens)*pricing.CachedInput +
+		float64(usage.OutputTokens)*pricing.Output
+
+	result.TotalCostUSD = cost / 1_000_000
+	result.HasCost = true
+	result.CostEstimated = true
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package loop
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group so it and any
+// children it spawns can be signaled together. This also keeps a terminal Ctrl-C
+// from reaching the command directly, so goralph decides when to forward it.
+func setProcessGroup(ctx context.Context, cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd, cancelSignal(ctx))
+	}
+}
+
+// signalProcessGroup sends sig to the command's entire process group
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+}
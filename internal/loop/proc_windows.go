@@ -0,0 +1,20 @@
+//go:build windows
+
+package loop
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows; the process is killed directly on cancel
+func setProcessGroup(ctx context.Context, cmd *exec.Cmd) {}
+
+// signalProcessGroup kills the command's process (Windows has no process group signals)
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
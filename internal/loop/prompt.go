@@ -0,0 +1,151 @@
+package loop
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// PromptData is the data the prompt file is rendered with as a Go text/template
+type PromptData struct {
+	Iteration        int                 // Current iteration (1-based)
+	MaxIterations    int                 // Iteration limit (0 = unlimited)
+	Branch           string              // Current git branch (empty if detached)
+	Mode             Mode                // Execution mode (ralph or rlm)
+	Phase            string              // Current RLM phase (empty in ralph mode)
+	PlanFile         string              // Session plan file path
+	SessionID        string              // Session identifier
+	Task             string              // Current task file name in task-queue mode (empty otherwise)
+	LastVerification *VerificationReport // Previous iteration's verification report (nil if it wasn't verified)
+	Vars             map[string]string   // Variables from config files and --var key=value
+}
+
+// newPromptData collects the template data for an iteration's prompt
+func newPromptData(cfg Config, iteration int, phase string, lastVerification *VerificationReport) PromptData {
+	branch, _ := getCurrentBranch(cfg.WorkDir)
+	var task string
+	if cfg.Tasks {
+		task = filepath.Base(cfg.PromptFile)
+	}
+	return PromptData{
+		Iteration:        iteration,
+		MaxIterations:    cfg.MaxIterations,
+		Branch:           branch,
+		Mode:             cfg.Mode,
+		Phase:            phase,
+		PlanFile:         cfg.PlanFile,
+		SessionID:        cfg.SessionID,
+		Task:             task,
+		LastVerification: lastVerification,
+		Vars:             cfg.Vars,
+	}
+}
+
+// renderPromptFile reads the prompt file and renders it as a text/template with the
+// given data. Beyond the data fields, templates can call:
+//
+//	include "path"  - contents of a file; relative paths resolve against the directory the
+//	                  agent runs in (the session's worktree, if any)
+//	gitlog N        - the last N commits, one line each
+//	var "key"       - a variable from --var or the config file ("" if unset)
+func renderPromptFile(cfg Config, data PromptData) ([]byte, error) {
+	content, err := os.ReadFile(cfg.PromptFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	funcs := template.FuncMap{
+		"include": func(path string) (string, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(cfg.WorkDir, path)
+			}
+			included, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to include %s: %w", path, err)
+			}
+			return string(included), nil
+		},
+		"gitlog": func(n int) (string, error) {
+			output, err := gitCommand(cfg.WorkDir, "log", "--oneline", "-n", strconv.Itoa(n)).Output()
+			if err != nil {
+				return "", fmt.Errorf("failed to read git log: %w", err)
+			}
+			return strings.TrimRight(string(output), "\n"), nil
+		},
+		"var": func(key string) string {
+			return data.Vars[key]
+		},
+	}
+
+	tmpl, err := template.New(filepath.Base(cfg.PromptFile)).Option("missingkey=zero").Funcs(funcs).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPrompt builds the prompt the active mode runner would send to the agent
+// for the given iteration, without invoking the agent. The plan and RLM state are
+// initialized in a throwaway directory, so nothing in .ralph/ is touched.
+func RenderPrompt(cfg Config, iteration int) ([]byte, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeRalph
+	}
+	if cfg.SessionID == "" {
+		cfg.SessionID = GenerateSessionID()
+	}
+	if cfg.PlanFile == "" {
+		cfg.PlanFile = GeneratePlanPath()
+	}
+	if iteration < 1 {
+		iteration = 1
+	}
+	// Always start from a fresh plan and state
+	cfg.Resume = ""
+
+	// In task-queue mode, render the pending task
+	if cfg.Tasks {
+		queue, err := LoadTaskQueue()
+		if err != nil {
+			return nil, err
+		}
+		task, ok := queue.Pending()
+		if !ok {
+			return nil, fmt.Errorf("all tasks in %s are complete", TasksDir)
+		}
+		cfg.PromptFile = taskPath(task)
+	}
+
+	if _, err := os.Stat(cfg.PromptFile); os.IsNotExist(err) {
+		return nil, fmt.Errorf("prompt file not found: %s", cfg.PromptFile)
+	}
+
+	scratch, err := os.MkdirTemp("", "goralph-prompt-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	cfg.ScratchDir = scratch
+
+	runner, err := NewModeRunner(cfg.Mode, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mode runner: %w", err)
+	}
+	if err := runner.Initialize(cfg); err != nil {
+		return nil, err
+	}
+	return runner.BuildPrompt(cfg, iteration)
+}
+
+// EstimateTokens approximates the token count of a prompt at about four bytes per token
+func EstimateTokens(prompt []byte) int {
+	return (len(prompt) + 3) / 4
+}
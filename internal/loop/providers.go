@@ -2,10 +2,12 @@ package loop
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os/exec"
+	"regexp"
 	"strings"
 )
 
@@ -13,29 +15,85 @@ import (
 type Provider interface {
 	// Name returns the provider name for display purposes
 	Name() string
-	// Model returns the model being used by this provider
+	// Model returns the model being used by this provider.
+	// Returns the model reported by the agent once known, otherwise the configured model
+	// (empty if the agent's default is used).
 	Model() string
-	// BuildCommand creates the command to execute with the given prompt
-	BuildCommand(prompt []byte) (*exec.Cmd, error)
+	// BuildCommand creates the command to execute with the given prompt.
+	// The command must be bound to ctx so timeouts and interrupts can stop the agent.
+	BuildCommand(ctx context.Context, prompt []byte) (*exec.Cmd, error)
 	// ParseOutput parses the agent output and returns the result summary
 	ParseOutput(r io.Reader, w io.Writer, logFile io.Writer) (*ResultMessage, error)
 }
 
-// NewProvider creates a new Provider instance based on the agent type
-func NewProvider(agent AgentProvider) (Provider, error) {
-	switch agent {
+// NewProvider creates a new Provider instance based on cfg.Agent.
+// An empty cfg.Model leaves model selection to the agent CLI.
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Agent {
 	case AgentClaude:
-		return &ClaudeProvider{}, nil
+		return &ClaudeProvider{model: cfg.Model}, nil
 	case AgentCodex:
-		return &CodexProvider{}, nil
+		return &CodexProvider{model: cfg.Model}, nil
+	case AgentGemini:
+		return &GeminiProvider{model: cfg.Model}, nil
+	case AgentCommand:
+		return NewCommandProvider(cfg.Command, cfg.Model)
 	default:
-		return nil, fmt.Errorf("unknown agent provider: %s", agent)
+		return nil, fmt.Errorf("unknown agent provider: %s", cfg.Agent)
 	}
 }
 
+// NewProviderChain creates the primary provider followed by one provider per fallback agent.
+// The configured model only applies to the primary agent; fallbacks use their CLI defaults.
+func NewProviderChain(cfg Config) ([]Provider, error) {
+	primary, err := NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	providers := []Provider{primary}
+
+	for _, agent := range cfg.FallbackAgents {
+		fallbackCfg := cfg
+		fallbackCfg.Agent = agent
+		fallbackCfg.Model = ""
+		provider, err := NewProvider(fallbackCfg)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// overloadKeywords are the phrases of rate limit, quota and overload errors
+var overloadKeywords = []string{"rate limit", "rate_limit", "ratelimit", "overloaded", "quota", "too many requests", "resource_exhausted", "service unavailable"}
+
+// overloadStatusPattern matches an overload HTTP status as a whole word next to a word
+// marking it as a status, so unrelated numbers (line numbers, byte counts, IDs) don't match
+var overloadStatusPattern = regexp.MustCompile(`(?i)\b(?:status|code|http|error)\b\W{0,3}(?:429|503|529)\b|\b(?:429|503|529)\b\W{0,3}(?:too many|overloaded|unavailable|rate)`)
+
+// isOverloadError reports whether an error code or message indicates a rate limit,
+// quota or overload condition that another provider may not be subject to. The code
+// is the structured error code or status, matched exactly.
+func isOverloadError(code, message string) bool {
+	switch strings.TrimSpace(code) {
+	case "429", "503", "529":
+		return true
+	}
+	text := strings.ToLower(code + " " + message)
+	for _, keyword := range overloadKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return overloadStatusPattern.MatchString(text)
+}
+
 // ClaudeProvider implements Provider for Claude Code agent
 type ClaudeProvider struct {
-	prompt []byte
+	prompt        []byte
+	model         string // Model passed via --model (empty for the CLI default)
+	reportedModel string // Model reported by the agent in its system init message
 }
 
 // Name returns the provider name
@@ -45,19 +103,25 @@ func (p *ClaudeProvider) Name() string {
 
 // Model returns the model being used
 func (p *ClaudeProvider) Model() string {
-	return "claude-sonnet-4-20250514"
+	if p.reportedModel != "" {
+		return p.reportedModel
+	}
+	return p.model
 }
 
 // BuildCommand creates the claude command
-func (p *ClaudeProvider) BuildCommand(prompt []byte) (*exec.Cmd, error) {
+func (p *ClaudeProvider) BuildCommand(ctx context.Context, prompt []byte) (*exec.Cmd, error) {
 	p.prompt = prompt
-	cmd := exec.Command("claude",
+	args := []string{
 		"-p",
 		"--dangerously-skip-permissions",
 		"--output-format=stream-json",
 		"--verbose",
-	)
-	return cmd, nil
+	}
+	if p.model != "" {
+		args = append(args, "--model", p.model)
+	}
+	return exec.CommandContext(ctx, "claude", args...), nil
 }
 
 // ParseOutput parses Claude's JSON stream output
@@ -97,6 +161,11 @@ func (p *ClaudeProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer
 				continue
 			}
 			result.HasCost = true // Claude provides cost data
+			result.Model = p.reportedModel
+			// Only a rate limit or overload is worth falling back to another provider
+			if result.IsError && isOverloadError("", result.Result) {
+				result.AgentError = fmt.Sprintf("error result (%s): %s", result.Subtype, truncateText(result.Result, 200))
+			}
 			resultMsg = &result
 
 		case "assistant":
@@ -108,8 +177,12 @@ func (p *ClaudeProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer
 			processClaudeUserMessage(line, w, state)
 
 		case "system":
-			// System messages (session info, etc.)
-			// Could log these if verbose mode is desired
+			// System init message reports the model actually in use
+			var sysMsg SystemMessage
+			if err := json.Unmarshal(line, &sysMsg); err == nil && sysMsg.Model != "" && sysMsg.Model != p.reportedModel {
+				p.reportedModel = sysMsg.Model
+				fmt.Fprintln(w, dimStyle.Render("Model: "+sysMsg.Model))
+			}
 		}
 	}
 
@@ -119,7 +192,7 @@ func (p *ClaudeProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer
 
 	// Check for completion promise and RLM markers in accumulated text
 	if resultMsg == nil {
-		resultMsg = &ResultMessage{}
+		resultMsg = &ResultMessage{Model: p.reportedModel}
 	}
 	accText := state.AccumulatedText.String()
 	if strings.Contains(accText, CompletionPromise) {
@@ -201,6 +274,7 @@ func processClaudeUserMessage(line []byte, w io.Writer, state *StreamState) {
 // CodexProvider implements Provider for OpenAI Codex agent
 type CodexProvider struct {
 	prompt []byte
+	model  string // Model passed via -m (empty for the CLI default)
 }
 
 // Name returns the provider name
@@ -209,20 +283,25 @@ func (p *CodexProvider) Name() string {
 }
 
 // Model returns the model being used
+// Codex does not report its model in the JSON stream, so this is the configured model.
 func (p *CodexProvider) Model() string {
-	return "codex-mini-latest"
+	return p.model
 }
 
 // BuildCommand creates the codex command
-func (p *CodexProvider) BuildCommand(prompt []byte) (*exec.Cmd, error) {
+func (p *CodexProvider) BuildCommand(ctx context.Context, prompt []byte) (*exec.Cmd, error) {
 	p.prompt = prompt
-	cmd := exec.Command("codex",
+	args := []string{
 		"exec",
 		"--json",
 		"--dangerously-bypass-approvals-and-sandbox",
-		"-",
-	)
-	return cmd, nil
+	}
+	if p.model != "" {
+		args = append(args, "-m", p.model)
+	}
+	// Read prompt from stdin
+	args = append(args, "-")
+	return exec.CommandContext(ctx, "codex", args...), nil
 }
 
 // ParseOutput parses Codex's JSON stream output
@@ -235,6 +314,7 @@ func (p *CodexProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer)
 	var turnCount int
 	var totalUsage CodexUsage
 	var hasError bool
+	var agentError string
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -271,6 +351,9 @@ func (p *CodexProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer)
 			if err := json.Unmarshal(line, &errEvent); err == nil && errEvent.Message != "" {
 				fmt.Fprintf(w, "\n%s\n", errorStyle.Render("Error: "+errEvent.Message))
 			}
+			if isOverloadError(errEvent.Code, errEvent.Message) {
+				agentError = strings.TrimSpace(errEvent.Code + " " + errEvent.Message)
+			}
 		case "item.started":
 			processCodexItemStarted(line, w, state)
 		case "item.completed":
@@ -296,8 +379,10 @@ func (p *CodexProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer)
 	// Build a result message for summary display
 	result := &ResultMessage{
 		Type:            "result",
+		Model:           p.model,
 		NumTurns:        turnCount,
 		IsError:         hasError,
+		AgentError:      agentError,
 		SessionComplete: sessionComplete,
 		Usage: Usage{
 			InputTokens:          totalUsage.InputTokens,
@@ -408,3 +493,12 @@ func detectRLMMarkers(text string, result *ResultMessage) {
 		result.ModeVerified = true
 	}
 }
+
+// truncateText shortens text to at most max bytes, adding an ellipsis if truncated
+func truncateText(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= max {
+		return text
+	}
+	return text[:max-3] + "..."
+}
@@ -0,0 +1,183 @@
+package loop
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Command provider input modes
+const (
+	// CommandInputStdin writes the prompt to the command's stdin
+	CommandInputStdin = "stdin"
+	// CommandInputFile writes the prompt to a file and passes its path via {prompt_file}
+	CommandInputFile = "file"
+)
+
+// Command provider output formats
+const (
+	// CommandFormatText treats output as plain text
+	CommandFormatText = "text"
+	// CommandFormatClaude parses output as Claude stream-json
+	CommandFormatClaude = "claude"
+	// CommandFormatCodex parses output as Codex JSONL
+	CommandFormatCodex = "codex"
+	// CommandFormatGemini parses output as Gemini stream-json
+	CommandFormatGemini = "gemini"
+)
+
+// PromptFilePlaceholder is replaced in command args with the prompt file path
+const PromptFilePlaceholder = "{prompt_file}"
+
+// CommandPromptFile is where file input mode writes the prompt, overwritten each iteration
+const CommandPromptFile = ".ralph/logs/prompt.md"
+
+// CommandProvider implements Provider for arbitrary agent CLIs configured entirely by data
+type CommandProvider struct {
+	cfg        CommandConfig
+	model      string
+	parser     Provider // Delegate for structured output formats (nil for text)
+	promptPath string   // Absolute path of CommandPromptFile, resolved on first use
+}
+
+// NewCommandProvider creates a CommandProvider, validating and defaulting the config
+func NewCommandProvider(cfg CommandConfig, model string) (*CommandProvider, error) {
+	if len(cfg.Args) == 0 {
+		return nil, fmt.Errorf("command provider requires command.args")
+	}
+
+	if cfg.Input == "" {
+		cfg.Input = CommandInputStdin
+	}
+	if cfg.Input != CommandInputStdin && cfg.Input != CommandInputFile {
+		return nil, fmt.Errorf("unknown command input: %q (valid options: stdin, file)", cfg.Input)
+	}
+
+	if cfg.Format == "" {
+		cfg.Format = CommandFormatText
+	}
+	var parser Provider
+	switch cfg.Format {
+	case CommandFormatText:
+	case CommandFormatClaude:
+		parser = &ClaudeProvider{model: model}
+	case CommandFormatCodex:
+		parser = &CodexProvider{model: model}
+	case CommandFormatGemini:
+		parser = &GeminiProvider{model: model}
+	default:
+		return nil, fmt.Errorf("unknown command format: %q (valid options: text, claude, codex, gemini)", cfg.Format)
+	}
+
+	if cfg.Name == "" {
+		cfg.Name = filepath.Base(cfg.Args[0])
+	}
+
+	return &CommandProvider{cfg: cfg, model: model, parser: parser}, nil
+}
+
+// Name returns the provider name
+func (p *CommandProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Model returns the model being used
+func (p *CommandProvider) Model() string {
+	if p.parser != nil {
+		return p.parser.Model()
+	}
+	return p.model
+}
+
+// BuildCommand creates the configured command
+func (p *CommandProvider) BuildCommand(ctx context.Context, prompt []byte) (*exec.Cmd, error) {
+	if p.cfg.Input == CommandInputStdin {
+		return exec.CommandContext(ctx, p.cfg.Args[0], p.cfg.Args[1:]...), nil
+	}
+
+	// File mode: write the prompt to a fixed file overwritten each iteration. The path
+	// is absolute because the command may run in a worktree.
+	if p.promptPath == "" {
+		path, err := filepath.Abs(CommandPromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve prompt file: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create prompt file directory: %w", err)
+		}
+		p.promptPath = path
+	}
+	if err := os.WriteFile(p.promptPath, prompt, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write prompt file: %w", err)
+	}
+
+	args := make([]string, len(p.cfg.Args))
+	substituted := false
+	for i, arg := range p.cfg.Args {
+		if strings.Contains(arg, PromptFilePlaceholder) {
+			substituted = true
+		}
+		args[i] = strings.ReplaceAll(arg, PromptFilePlaceholder, p.promptPath)
+	}
+	// Append the path if the args don't reference it explicitly
+	if !substituted {
+		args = append(args, p.promptPath)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	// Prompt goes via file, so give the command an empty stdin
+	cmd.Stdin = bytes.NewReader(nil)
+	return cmd, nil
+}
+
+// ParseOutput parses the command output according to the configured format
+func (p *CommandProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer) (*ResultMessage, error) {
+	if p.parser != nil {
+		return p.parser.ParseOutput(r, w, logFile)
+	}
+	return parseTextOutput(r, w, logFile, p.model)
+}
+
+// parseTextOutput streams plain text output and scans it for the completion promise and RLM markers
+func parseTextOutput(r io.Reader, w io.Writer, logFile io.Writer, model string) (*ResultMessage, error) {
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	var accText strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Write raw line to log file
+		if logFile != nil {
+			logFile.Write([]byte(line + "\n"))
+		}
+
+		FormatTextDelta(w, line+"\n")
+		accText.WriteString(line)
+		accText.WriteString("\n")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	text := accText.String()
+	result := &ResultMessage{
+		Type:            "result",
+		Model:           model,
+		Result:          text,
+		SessionComplete: strings.Contains(text, CompletionPromise),
+	}
+
+	// Detect RLM markers
+	detectRLMMarkers(text, result)
+
+	return result, nil
+}
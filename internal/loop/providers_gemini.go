@@ -0,0 +1,196 @@
+package loop
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// GeminiProvider implements Provider for Google Gemini CLI agent
+type GeminiProvider struct {
+	prompt        []byte
+	model         string // Model passed via -m (empty for the CLI default)
+	reportedModel string // Model reported by the agent in its init event
+}
+
+// Name returns the provider name
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+// Model returns the model being used
+func (p *GeminiProvider) Model() string {
+	if p.reportedModel != "" {
+		return p.reportedModel
+	}
+	return p.model
+}
+
+// BuildCommand creates the gemini command
+// The prompt is read from stdin, which puts the CLI in headless mode.
+func (p *GeminiProvider) BuildCommand(ctx context.Context, prompt []byte) (*exec.Cmd, error) {
+	p.prompt = prompt
+	args := []string{
+		"--yolo",
+		"--output-format", "stream-json",
+	}
+	if p.model != "" {
+		args = append(args, "-m", p.model)
+	}
+	return exec.CommandContext(ctx, "gemini", args...), nil
+}
+
+// ParseOutput parses Gemini's stream-json output
+func (p *GeminiProvider) ParseOutput(r io.Reader, w io.Writer, logFile io.Writer) (*ResultMessage, error) {
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	state := NewStreamState()
+	var turnCount int
+	var inAssistantTurn bool
+	var hasError bool
+	var agentError string
+	var stats *GeminiStats
+	var resultText strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		// Write raw JSON line to log file
+		if logFile != nil {
+			logFile.Write(line)
+			logFile.Write([]byte("\n"))
+		}
+
+		// Parse the event type
+		var event GeminiEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "init":
+			var initEvent GeminiInitEvent
+			if err := json.Unmarshal(line, &initEvent); err == nil && initEvent.Model != "" && initEvent.Model != p.reportedModel {
+				p.reportedModel = initEvent.Model
+				fmt.Fprintln(w, dimStyle.Render("Model: "+initEvent.Model))
+			}
+		case "message":
+			var msgEvent GeminiMessageEvent
+			if err := json.Unmarshal(line, &msgEvent); err != nil || msgEvent.Role != "assistant" {
+				continue
+			}
+			// Consecutive assistant messages belong to the same turn
+			if !inAssistantTurn {
+				turnCount++
+				inAssistantTurn = true
+				resultText.Reset()
+			}
+			if msgEvent.Content != "" {
+				FormatTextDelta(w, msgEvent.Content)
+				state.AccumulatedText.WriteString(msgEvent.Content)
+				resultText.WriteString(msgEvent.Content)
+				state.NeedsNewline = !strings.HasSuffix(msgEvent.Content, "\n")
+			}
+		case "tool_use":
+			inAssistantTurn = false
+			var toolEvent GeminiToolUseEvent
+			if err := json.Unmarshal(line, &toolEvent); err != nil || toolEvent.ToolID == "" {
+				continue
+			}
+			if state.ActiveTools[toolEvent.ToolID] != "" {
+				continue
+			}
+			toolName := toolEvent.ToolName
+			if toolName == "" {
+				toolName = "tool"
+			}
+			state.ActiveTools[toolEvent.ToolID] = toolName
+			// Add newline before tool if needed (text didn't end with one)
+			if state.NeedsNewline {
+				fmt.Fprintln(w)
+				state.NeedsNewline = false
+			}
+			FormatToolStart(w, toolEvent.ToolID, toolName, state)
+		case "tool_result":
+			inAssistantTurn = false
+			var resultEvent GeminiToolResultEvent
+			if err := json.Unmarshal(line, &resultEvent); err != nil {
+				continue
+			}
+			toolName := state.ActiveTools[resultEvent.ToolID]
+			if toolName != "" && !state.CompletedTools[resultEvent.ToolID] {
+				state.CompletedTools[resultEvent.ToolID] = true
+				FormatToolComplete(w, resultEvent.ToolID, toolName, state)
+			}
+		case "error":
+			var errEvent GeminiErrorEvent
+			if err := json.Unmarshal(line, &errEvent); err == nil && errEvent.Message != "" {
+				fmt.Fprintf(w, "\n%s\n", errorStyle.Render("Error: "+errEvent.Message))
+			}
+			// Warnings are informational unless they report a rate limit; anything
+			// else fails the iteration
+			if errEvent.Severity != "warning" {
+				hasError = true
+			}
+			if agentError == "" && (errEvent.Severity != "warning" || isOverloadError("", errEvent.Message)) {
+				agentError = "error event"
+				if errEvent.Message != "" {
+					agentError += ": " + truncateText(errEvent.Message, 200)
+				}
+			}
+		case "result":
+			var resultEvent GeminiResultEvent
+			if err := json.Unmarshal(line, &resultEvent); err != nil {
+				continue
+			}
+			stats = &resultEvent.Stats
+			if resultEvent.Status != "" && resultEvent.Status != "success" {
+				hasError = true
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if state.NeedsNewline {
+		fmt.Fprintln(w)
+	}
+
+	// Check for completion promise and RLM markers in accumulated text
+	accText := state.AccumulatedText.String()
+
+	// Build a result message for summary display
+	result := &ResultMessage{
+		Type:            "result",
+		Model:           p.Model(),
+		NumTurns:        turnCount,
+		IsError:         hasError,
+		AgentError:      agentError,
+		Result:          resultText.String(),
+		SessionComplete: strings.Contains(accText, CompletionPromise),
+	}
+	if stats != nil {
+		result.DurationMs = stats.DurationMs
+		result.Usage = Usage{
+			InputTokens:          stats.InputTokens,
+			OutputTokens:         stats.OutputTokens,
+			CacheReadInputTokens: stats.Cached,
+		}
+	}
+
+	// Detect RLM markers
+	detectRLMMarkers(accText, result)
+
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package loop
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseGeminiFixture runs GeminiProvider.ParseOutput over a recorded stream-json
+// fixture in testdata/ and returns the result and the rendered output
+func parseGeminiFixture(t *testing.T, name string) (*ResultMessage, string) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	var out, log bytes.Buffer
+	p := &GeminiProvider{}
+	result, err := p.ParseOutput(f, &out, &log)
+	if err != nil {
+		t.Fatalf("ParseOutput returned error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("ParseOutput returned no result")
+	}
+	if log.Len() == 0 {
+		t.Error("expected raw events to be written to the log")
+	}
+	return result, out.String()
+}
+
+func TestGeminiParseOutputSuccess(t *testing.T) {
+	result, out := parseGeminiFixture(t, "gemini_success.jsonl")
+
+	if result.Model != "gemini-2.5-pro" {
+		t.Errorf("Model = %q, want gemini-2.5-pro", result.Model)
+	}
+	if result.NumTurns != 3 {
+		t.Errorf("NumTurns = %d, want 3", result.NumTurns)
+	}
+	want := Usage{InputTokens: 15210, OutputTokens: 680, CacheReadInputTokens: 9200}
+	if result.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", result.Usage, want)
+	}
+	if result.DurationMs != 11390 {
+		t.Errorf("DurationMs = %d, want 11390", result.DurationMs)
+	}
+	if result.IsError || result.AgentError != "" {
+		t.Errorf("unexpected error: IsError=%v AgentError=%q", result.IsError, result.AgentError)
+	}
+	if !result.SessionComplete {
+		t.Error("expected the completion promise to be detected")
+	}
+	if !strings.Contains(result.Result, "All tasks are done.") {
+		t.Errorf("Result = %q, want the last assistant turn", result.Result)
+	}
+
+	for _, tool := range []string{"read_file", "run_shell_command"} {
+		if !strings.Contains(out, tool+" running...") {
+			t.Errorf("output missing start indicator for %s:\n%s", tool, out)
+		}
+		if !strings.Contains(out, tool+" done") {
+			t.Errorf("output missing complete indicator for %s:\n%s", tool, out)
+		}
+	}
+	if strings.Contains(out, "Implement the next task") {
+		t.Error("user messages should not be rendered")
+	}
+}
+
+func TestGeminiParseOutputError(t *testing.T) {
+	result, out := parseGeminiFixture(t, "gemini_error.jsonl")
+
+	if !result.IsError {
+		t.Error("expected IsError for an error event")
+	}
+	if !strings.Contains(result.AgentError, "permission denied") {
+		t.Errorf("AgentError = %q, want the error message", result.AgentError)
+	}
+	if result.SessionComplete {
+		t.Error("unexpected completion")
+	}
+	if result.NumTurns != 1 {
+		t.Errorf("NumTurns = %d, want 1", result.NumTurns)
+	}
+	if !strings.Contains(out, "Error: Tool execution failed: permission denied") {
+		t.Errorf("output missing error:\n%s", out)
+	}
+}
+
+func TestGeminiParseOutputRateLimit(t *testing.T) {
+	result, _ := parseGeminiFixture(t, "gemini_rate_limit.jsonl")
+
+	if !result.IsError {
+		t.Error("expected IsError for a failed result status")
+	}
+	if !strings.Contains(result.AgentError, "429 Too Many Requests") {
+		t.Errorf("AgentError = %q, want the rate-limit message", result.AgentError)
+	}
+	if result.NumTurns != 0 {
+		t.Errorf("NumTurns = %d, want 0", result.NumTurns)
+	}
+}
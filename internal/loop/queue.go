@@ -0,0 +1,224 @@
+package loop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TasksDir is the directory of task prompt files worked in order in task-queue mode
+	TasksDir = ".ralph/tasks"
+	// QueueFile records task-queue progress so a restart picks up where it left off
+	QueueFile = ".ralph/queue.json"
+)
+
+// Task statuses recorded in the queue file
+const (
+	TaskComplete = "complete"       // Agent emitted the completion promise
+	TaskCapped   = "max_iterations" // Task reached its iteration cap
+)
+
+// QueueEntry records the progress of one task
+type QueueEntry struct {
+	Task       string     `json:"task"` // File name within TasksDir
+	PlanFile   string     `json:"plan_file"`
+	SessionID  string     `json:"session_id"`
+	Iterations int        `json:"iterations"`
+	Status     string     `json:"status,omitempty"` // Empty while the task is in progress
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+}
+
+// QueueState is the persisted state of the task queue
+type QueueState struct {
+	Completed []QueueEntry `json:"completed"`
+	Current   *QueueEntry  `json:"current,omitempty"`
+}
+
+// TaskQueue works through the prompt files in TasksDir in name order
+type TaskQueue struct {
+	Tasks []string // Task file names, sorted
+	State QueueState
+}
+
+// LoadTaskQueue lists the tasks in TasksDir and loads the queue state
+func LoadTaskQueue() (*TaskQueue, error) {
+	entries, err := os.ReadDir(TasksDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("task directory not found: %s", TasksDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task directory: %w", err)
+	}
+
+	q := &TaskQueue{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			q.Tasks = append(q.Tasks, entry.Name())
+		}
+	}
+	if len(q.Tasks) == 0 {
+		return nil, fmt.Errorf("no tasks found in %s", TasksDir)
+	}
+	sort.Strings(q.Tasks)
+
+	data, err := os.ReadFile(QueueFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue state: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.State); err != nil {
+		return nil, fmt.Errorf("failed to parse queue state: %w", err)
+	}
+
+	// Forget a current task whose file has since been removed
+	if q.State.Current != nil && !q.hasTask(q.State.Current.Task) {
+		q.State.Current = nil
+	}
+	return q, nil
+}
+
+// hasTask reports whether a task file is still in the queue
+func (q *TaskQueue) hasTask(task string) bool {
+	for _, t := range q.Tasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompleted reports whether a task has been recorded as finished
+func (q *TaskQueue) isCompleted(task string) bool {
+	for _, entry := range q.State.Completed {
+		if entry.Task == task {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the task to work on: the current task if one is in progress,
+// otherwise the first task not yet finished
+func (q *TaskQueue) Pending() (string, bool) {
+	if q.State.Current != nil {
+		return q.State.Current.Task, true
+	}
+	return q.next()
+}
+
+// next returns the first task that is neither finished nor in progress
+func (q *TaskQueue) next() (string, bool) {
+	for _, task := range q.Tasks {
+		if q.isCompleted(task) || (q.State.Current != nil && q.State.Current.Task == task) {
+			continue
+		}
+		return task, true
+	}
+	return "", false
+}
+
+// Remaining returns the number of tasks not yet finished, including the current one
+func (q *TaskQueue) Remaining() int {
+	remaining := 0
+	for _, task := range q.Tasks {
+		if !q.isCompleted(task) {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// Current returns the task in progress, or nil
+func (q *TaskQueue) Current() *QueueEntry {
+	return q.State.Current
+}
+
+// Start records a task as in progress with its plan file and session
+func (q *TaskQueue) Start(task, planFile, sessionID string) error {
+	q.State.Current = &QueueEntry{
+		Task:      task,
+		PlanFile:  planFile,
+		SessionID: sessionID,
+		StartedAt: time.Now(),
+	}
+	return q.save()
+}
+
+// RecordIteration counts an iteration against the current task
+func (q *TaskQueue) RecordIteration() error {
+	if q.State.Current == nil {
+		return nil
+	}
+	q.State.Current.Iterations++
+	return q.save()
+}
+
+// MarkCurrent sets the status of the current task, which moves it to the
+// completed list on the next Finish
+func (q *TaskQueue) MarkCurrent(status string) error {
+	if q.State.Current == nil {
+		return nil
+	}
+	q.State.Current.Status = status
+	return q.save()
+}
+
+// Finished returns the status the current task has finished with: the status
+// set by MarkCurrent, TaskCapped once it reaches maxIterations, or "" while
+// it is still in progress
+func (q *TaskQueue) Finished(maxIterations int) string {
+	current := q.State.Current
+	if current == nil {
+		return ""
+	}
+	if current.Status != "" {
+		return current.Status
+	}
+	if maxIterations > 0 && current.Iterations >= maxIterations {
+		return TaskCapped
+	}
+	return ""
+}
+
+// Finish moves the current task to the completed list with the given status and
+// returns the next task, if any
+func (q *TaskQueue) Finish(status string) (string, bool, error) {
+	if current := q.State.Current; current != nil {
+		now := time.Now()
+		current.Status = status
+		current.EndedAt = &now
+		q.State.Completed = append(q.State.Completed, *current)
+		q.State.Current = nil
+	}
+	if err := q.save(); err != nil {
+		return "", false, err
+	}
+	task, ok := q.next()
+	return task, ok, nil
+}
+
+// save writes the queue state to QueueFile
+func (q *TaskQueue) save() error {
+	data, err := json.MarshalIndent(q.State, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue state: %w", err)
+	}
+	if err := os.WriteFile(QueueFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write queue state: %w", err)
+	}
+	return nil
+}
+
+// taskPath returns the prompt file path of a task
+func taskPath(task string) string {
+	return filepath.Join(TasksDir, task)
+}
@@ -0,0 +1,103 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"os/exec"
+	"time"
+)
+
+// RetryPolicy controls how failed iterations and pushes are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first (1 = no retries)
+	BackoffBase time.Duration // Delay before the first retry, doubled for each subsequent retry
+	BackoffCap  time.Duration // Upper bound on the delay between retries (0 = no cap)
+	Jitter      float64       // Random fraction (0-1) the delay may vary by in either direction
+}
+
+// DefaultRetryPolicy returns the built-in retry policy, which does not retry
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 1,
+		BackoffBase: 10 * time.Second,
+		BackoffCap:  5 * time.Minute,
+		Jitter:      0.2,
+	}
+}
+
+// Backoff returns the delay before the retry following the given (1-based) failed attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BackoffBase
+	for i := 1; i < attempt; i++ {
+		// Stop doubling at the cap, or before overflowing when there is none
+		if (p.BackoffCap > 0 && delay >= p.BackoffCap) || delay > math.MaxInt64/2 {
+			break
+		}
+		delay *= 2
+	}
+	if p.BackoffCap > 0 && delay > p.BackoffCap {
+		delay = p.BackoffCap
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// RetryableError marks a failure that may succeed if attempted again,
+// such as an agent crash, a transient network error or a rejected push
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// retryable wraps err as a RetryableError, unless it is nil or clearly fatal
+// (e.g. the agent binary is not installed)
+func retryable(err error) error {
+	if err == nil || errors.Is(err, exec.ErrNotFound) {
+		return err
+	}
+	return &RetryableError{Err: err}
+}
+
+// IsRetryable reports whether err is a failure worth retrying
+func IsRetryable(err error) bool {
+	var retryErr *RetryableError
+	return errors.As(err, &retryErr)
+}
+
+// withRetry runs fn until it succeeds, fails with a non-retryable error, the policy's
+// attempts are exhausted or ctx is canceled. Each retry is announced on the config output.
+func withRetry(ctx context.Context, cfg Config, label string, fn func(attempt int) error) error {
+	policy := cfg.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || !IsRetryable(err) || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := policy.Backoff(attempt)
+		FormatRetry(cfg.Output, label, attempt+1, policy.MaxAttempts, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package loop
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile is the path to the project config file
+const ConfigFile = ".ralph/config.yaml"
+
+// InitOptions controls how goralph init scaffolds a project
+type InitOptions struct {
+	Template string // Prompt template name (see PromptTemplates)
+	Force    bool   // Overwrite existing files
+}
+
+// PromptTemplates holds the starter PROMPT.md contents selectable with goralph init
+var PromptTemplates = map[string]string{
+	"feature": `# Goal
+
+Implement the following feature: <describe the feature>
+
+## Requirements
+
+- <requirement>
+- <requirement>
+
+## Acceptance Criteria
+
+- The feature works as described above
+- New behavior is covered by tests
+- Existing tests and the build pass
+
+## Notes
+
+- Follow the existing code style and project structure
+- Keep changes focused on this feature
+`,
+	"bugfix": `# Goal
+
+Fix the following bug: <describe the bug>
+
+## Steps to Reproduce
+
+1. <step>
+2. <step>
+
+## Expected Behavior
+
+<what should happen>
+
+## Actual Behavior
+
+<what happens instead, including any error messages>
+
+## Acceptance Criteria
+
+- A regression test reproduces the bug and now passes
+- The root cause is fixed, not just the symptom
+- Existing tests and the build pass
+`,
+	"refactor": `# Goal
+
+Refactor <area of the codebase> to <desired outcome>.
+
+## Motivation
+
+<why the current structure is a problem>
+
+## Constraints
+
+- Behavior must not change; existing tests must keep passing without modification
+- Make small, incremental changes that each leave the build green
+- Do not introduce new dependencies
+
+## Acceptance Criteria
+
+- <measurable outcome, e.g. duplicated logic extracted into a single package>
+- Existing tests and the build pass
+`,
+	"test-coverage": `# Goal
+
+Improve test coverage of <package or area>.
+
+## Focus
+
+- Untested public functions and error paths
+- Edge cases: empty input, boundaries, invalid data
+- Regression tests for previously fixed bugs
+
+## Constraints
+
+- Do not change production behavior; if a bug is found, note it in the plan instead of fixing it
+- Follow the existing test layout and helpers
+- Tests must be deterministic and fast
+
+## Acceptance Criteria
+
+- Each iteration adds meaningful tests for one area
+- All tests pass
+`,
+}
+
+// TemplateNames returns the available prompt template names, sorted
+func TemplateNames() []string {
+	names := make([]string, 0, len(PromptTemplates))
+	for name := range PromptTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ralphGitignore lists the .ralph/ files that are local to a machine and shouldn't be committed
+const ralphGitignore = `# goralph local state
+logs/
+state/
+sessions/
+worktrees/
+rejected/
+queue.json
+`
+
+// InitProject scaffolds .ralph/ in the current directory: a PROMPT.md from the
+// selected template, a starter config with the detected verification commands
+// and a .gitignore for local state. Existing files are only overwritten with Force.
+func InitProject(w io.Writer, opts InitOptions) error {
+	if opts.Template == "" {
+		opts.Template = "feature"
+	}
+	prompt, ok := PromptTemplates[opts.Template]
+	if !ok {
+		return fmt.Errorf("unknown template: %q (valid options: %s)", opts.Template, strings.Join(TemplateNames(), ", "))
+	}
+
+	verifyCommands := DetectProjectType()
+	files := []struct {
+		path    string
+		content string
+	}{
+		{PromptFile, prompt},
+		{ConfigFile, starterConfig(verifyCommands)},
+		{filepath.Join(".ralph", ".gitignore"), ralphGitignore},
+	}
+
+	// Check everything up front so a refusal leaves nothing half-written
+	if !opts.Force {
+		var existing []string
+		for _, f := range files {
+			if _, err := os.Stat(f.path); err == nil {
+				existing = append(existing, f.path)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to check %s: %w", f.path, err)
+			}
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("refusing to overwrite %s (use --force to overwrite)", strings.Join(existing, ", "))
+		}
+	}
+
+	if err := os.MkdirAll(".ralph", 0755); err != nil {
+		return fmt.Errorf("failed to create .ralph directory: %w", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		fmt.Fprintln(w, successStyle.Render("✓")+" Created "+f.path)
+	}
+
+	if len(verifyCommands) > 0 {
+		fmt.Fprintln(w, dimStyle.Render("Detected verification commands: "+strings.Join(verifyCommands, ", ")))
+	} else {
+		fmt.Fprintln(w, dimStyle.Render("No project type detected; add verify_commands to "+ConfigFile+" to enable verification"))
+	}
+	fmt.Fprintln(w, dimStyle.Render("Edit "+PromptFile+" to describe the task, then run: goralph run"))
+	return nil
+}
+
+// starterConfig renders the starter .ralph/config.yaml, enabling verification
+// when verification commands were detected
+func starterConfig(verifyCommands []string) string {
+	var b strings.Builder
+	b.WriteString(`# goralph project config. Flags, GORALPH_* environment variables and
+# profiles override these settings; see the README for all options.
+
+agent: claude
+# model: claude-sonnet-4-5
+mode: ralph
+max_iterations: 10
+no_push: false
+
+# goralph refuses to run on main or master; work on a ralph/<session-id> branch instead
+# branch: auto
+
+`)
+	if len(verifyCommands) > 0 {
+		b.WriteString("verify: true\nverify_commands:\n")
+		for _, cmd := range verifyCommands {
+			b.WriteString("  - " + strconv.Quote(cmd) + "\n")
+		}
+	} else {
+		b.WriteString("verify: false\n# verify_commands:\n#   - \"make test\"\n")
+	}
+	b.WriteString(`
+# Stop the session once it has cost this much (USD, 0 = unlimited)
+max_cost: 0
+
+# Kill the agent if a single iteration runs longer than this (0 = no limit)
+iteration_timeout: 0s
+`)
+	return b.String()
+}
@@ -0,0 +1,565 @@
+package loop
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionsDir is the directory for per-session metadata
+const SessionsDir = ".ralph/sessions"
+
+// Session exit reasons
+const (
+	ExitComplete      = "complete"       // Agent emitted the completion promise
+	ExitMaxIterations = "max_iterations" // Iteration limit reached
+	ExitInterrupted   = "interrupted"    // User requested a stop after the current iteration
+	ExitAborted       = "aborted"        // User aborted the agent mid-iteration
+	ExitBudget        = "budget"         // Cost or token budget exhausted
+	ExitTimeout       = "timeout"        // Iteration timed out with the stop policy
+	ExitError         = "error"          // Iteration, push or other failure
+)
+
+// GenerateSessionID returns a new sortable, branch-name-safe session ID
+func GenerateSessionID() string {
+	return time.Now().Format("20060102-150405") + "-" + uuid.New().String()[:4]
+}
+
+// SessionMeta records what is needed to identify and resume a session.
+// It is written to .ralph/sessions/<id>/session.json when the session starts
+// and updated after every iteration.
+type SessionMeta struct {
+	SessionID       string        `json:"session_id"`
+	StartedAt       time.Time     `json:"started_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	EndedAt         *time.Time    `json:"ended_at,omitempty"` // When the last run of the session exited
+	Status          string        `json:"status"`             // "running" or the exit reason of the last run
+	Mode            Mode          `json:"mode"`
+	Agent           AgentProvider `json:"agent"`
+	Model           string        `json:"model,omitempty"`
+	Branch          string        `json:"branch"`
+	Worktree        string        `json:"worktree,omitempty"`         // Worktree the agent runs in (worktree sessions only)
+	WorktreeCleanup string        `json:"worktree_cleanup,omitempty"` // Worktree cleanup policy
+	PromptFile      string        `json:"prompt_file"`
+	PromptHash      string        `json:"prompt_hash"` // SHA-256 of the prompt file when the session started
+	PlanFile        string        `json:"plan_file"`
+	StartHead       string        `json:"start_head"` // HEAD commit when the session started
+	Iterations      int           `json:"iterations"` // Iterations run so far
+	CostUSD         float64       `json:"cost_usd"`
+	CostEstimated   bool          `json:"cost_estimated"`
+}
+
+// IterationRecord summarizes a single iteration of a session. Records are appended
+// to .ralph/sessions/<id>/iterations.jsonl.
+type IterationRecord struct {
+	Iteration    int       `json:"iteration"`
+	EndedAt      time.Time `json:"ended_at"`
+	Status       string    `json:"status"` // ok, empty, error, timeout, verify_failed or failed (iteration errored)
+	Agent        string    `json:"agent,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	DurationMs   int       `json:"duration_ms"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+	LogFile      string    `json:"log_file,omitempty"`
+	StartHead    string    `json:"start_head,omitempty"` // HEAD commit before the iteration
+	Head         string    `json:"head,omitempty"`       // HEAD commit after the iteration
+	Commit       string    `json:"commit,omitempty"`     // Commit made by goralph (auto-commit only)
+	Rejected     string    `json:"rejected,omitempty"`   // Patch of changes reverted after failed verification
+}
+
+// SessionRunning is the status of a session whose loop has not exited
+const SessionRunning = "running"
+
+// sessionDir returns the metadata directory of a session
+func sessionDir(sessionID string) string {
+	return filepath.Join(SessionsDir, sessionID)
+}
+
+// LoadSessionMeta reads the metadata of a session
+func LoadSessionMeta(sessionID string) (*SessionMeta, error) {
+	data, err := os.ReadFile(filepath.Join(sessionDir(sessionID), "session.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session metadata: %w", err)
+	}
+
+	var meta SessionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse session metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// saveSessionMeta writes the metadata of a session
+func saveSessionMeta(meta *SessionMeta) error {
+	meta.UpdatedAt = time.Now()
+	dir := sessionDir(meta.SessionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session metadata: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "session.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session metadata: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the metadata of all recorded sessions, oldest first
+func ListSessions() ([]SessionMeta, error) {
+	entries, err := os.ReadDir(SessionsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []SessionMeta
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := LoadSessionMeta(entry.Name())
+		if err != nil {
+			continue // Not a resumable session (e.g. summary only)
+		}
+		sessions = append(sessions, *meta)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+	})
+	return sessions, nil
+}
+
+// LoadSessionIterations reads the iteration records of a session
+func LoadSessionIterations(sessionID string) ([]IterationRecord, error) {
+	f, err := os.Open(filepath.Join(sessionDir(sessionID), "iterations.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read iteration records: %w", err)
+	}
+	defer f.Close()
+
+	var records []IterationRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record IterationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // Skip malformed lines
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// appendIterationRecord appends an iteration record to a session's iterations.jsonl
+func appendIterationRecord(sessionID string, record IterationRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal iteration record: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(sessionDir(sessionID), "iterations.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open iteration records: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write iteration record: %w", err)
+	}
+	return nil
+}
+
+// RemoveSession deletes a session's metadata along with its plan file and iteration logs
+func RemoveSession(sessionID string) error {
+	meta, err := LoadSessionMeta(sessionID)
+	if err != nil {
+		return err
+	}
+	records, err := LoadSessionIterations(sessionID)
+	if err != nil {
+		return err
+	}
+
+	// A session's worktree goes with it; its branch and commits are kept
+	if meta.Worktree != "" {
+		if _, err := os.Stat(meta.Worktree); err == nil {
+			if err := removeWorktree(meta.Worktree); err != nil {
+				return err
+			}
+		}
+	}
+
+	paths := []string{filepath.Join(meta.Worktree, meta.PlanFile)}
+	for _, record := range records {
+		paths = append(paths, record.LogFile)
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	if err := os.RemoveAll(sessionDir(sessionID)); err != nil {
+		return fmt.Errorf("failed to remove session directory: %w", err)
+	}
+	return nil
+}
+
+// ShowSession renders a session's metadata, iteration records and plan
+func ShowSession(w io.Writer, sessionID string) error {
+	meta, err := LoadSessionMeta(sessionID)
+	if err != nil {
+		return err
+	}
+	records, err := LoadSessionIterations(sessionID)
+	if err != nil {
+		return err
+	}
+	// The plan may have been deleted; show the rest regardless
+	plan, _ := os.ReadFile(filepath.Join(meta.Worktree, meta.PlanFile))
+
+	FormatSessionDetail(w, *meta, records, string(plan))
+	return nil
+}
+
+// ResolveSessionID maps "latest" to the most recently started session and
+// checks that any other ID exists
+func ResolveSessionID(id string) (string, error) {
+	if id != "latest" {
+		if _, err := LoadSessionMeta(id); err != nil {
+			return "", err
+		}
+		return id, nil
+	}
+
+	sessions, err := ListSessions()
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "", fmt.Errorf("no sessions found in %s", SessionsDir)
+	}
+	return sessions[len(sessions)-1].SessionID, nil
+}
+
+// hashFile returns the hex SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// resumeSession loads the session named by cfg.Resume and points cfg at its
+// plan file and mode. It refuses to resume if the prompt file changed since the
+// session started, unless cfg.Force is set.
+func resumeSession(cfg *Config) (*SessionMeta, error) {
+	id, err := ResolveSessionID(cfg.Resume)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := LoadSessionMeta(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Force {
+		hash, err := hashFile(cfg.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		if hash != meta.PromptHash {
+			return nil, fmt.Errorf("prompt file %s changed since session %s started (use --force to resume anyway)", cfg.PromptFile, id)
+		}
+	}
+
+	cfg.SessionID = meta.SessionID
+	cfg.PlanFile = meta.PlanFile
+	cfg.Mode = meta.Mode
+	// Carry on where the session's work lives
+	if cfg.Branch == "" {
+		cfg.Branch = meta.Branch
+	}
+	if meta.Worktree != "" {
+		cfg.Worktree = true
+	}
+	return meta, nil
+}
+
+// SessionSummary holds the cumulative statistics of a session
+type SessionSummary struct {
+	SessionID          string    `json:"session_id"`
+	StartedAt          time.Time `json:"started_at"`
+	EndedAt            time.Time `json:"ended_at"`
+	WallTimeMs         int64     `json:"wall_time_ms"`
+	ExitReason         string    `json:"exit_reason"`
+	Iterations         int       `json:"iterations"`
+	Succeeded          int       `json:"succeeded"`
+	Failed             int       `json:"failed"`
+	Skipped            int       `json:"skipped"` // Push skipped due to failed verification
+	Empty              int       `json:"empty"`   // Iterations that produced no commits where one was expected
+	InputTokens        int       `json:"input_tokens"`
+	OutputTokens       int       `json:"output_tokens"`
+	CostUSD            float64   `json:"cost_usd"`
+	CostEstimated      bool      `json:"cost_estimated"`
+	HasCost            bool      `json:"has_cost"` // Some iteration reported or estimated a cost
+	VerificationRuns   int       `json:"verification_runs"`
+	VerificationPassed int       `json:"verification_passed"`
+	Commits            int       `json:"commits"`
+	FilesChanged       int       `json:"files_changed"`
+	TasksCompleted     int       `json:"tasks_completed"`
+	TasksRemaining     int       `json:"tasks_remaining"`
+}
+
+// sessionTracker accumulates iteration outcomes into a SessionSummary and keeps
+// the session metadata up to date
+type sessionTracker struct {
+	meta        *SessionMeta
+	summary     SessionSummary
+	runStart    time.Time // When this run of the session started
+	priorWallMs int64     // Wall time of earlier runs of a resumed session
+}
+
+// newSessionTracker starts tracking a session and writes its metadata. A resumed
+// session (meta != nil) continues from its previous summary; otherwise a new
+// session is recorded with the current prompt hash and HEAD commit.
+func newSessionTracker(cfg Config, meta *SessionMeta, branch, model string) (*sessionTracker, error) {
+	now := time.Now()
+	t := &sessionTracker{meta: meta, runStart: now}
+
+	if meta == nil {
+		hash, err := hashFile(cfg.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		head, _ := getHeadCommit(cfg.WorkDir)
+		t.meta = &SessionMeta{
+			SessionID:  cfg.SessionID,
+			StartedAt:  now,
+			Mode:       cfg.Mode,
+			Agent:      cfg.Agent,
+			Model:      model,
+			Branch:     branch,
+			PromptFile: cfg.PromptFile,
+			PromptHash: hash,
+			PlanFile:   cfg.PlanFile,
+			StartHead:  head,
+		}
+		t.summary = SessionSummary{SessionID: cfg.SessionID, StartedAt: now}
+	} else if previous, err := loadSessionSummary(meta.SessionID); err == nil {
+		t.summary = *previous
+		t.priorWallMs = previous.WallTimeMs
+	} else {
+		t.summary = SessionSummary{SessionID: meta.SessionID, StartedAt: meta.StartedAt}
+	}
+
+	t.meta.Status = SessionRunning
+	t.meta.EndedAt = nil
+	t.meta.Worktree = cfg.WorkDir
+	t.meta.WorktreeCleanup = ""
+	if cfg.WorkDir != "" {
+		t.meta.WorktreeCleanup = cfg.WorktreeCleanup
+	}
+	if err := saveSessionMeta(t.meta); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// switchTask points the session metadata at a new task's prompt and plan file
+// in task-queue mode, so a resume checks the right prompt
+func (t *sessionTracker) switchTask(cfg Config) error {
+	hash, err := hashFile(cfg.PromptFile)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	t.meta.PromptFile = cfg.PromptFile
+	t.meta.PromptHash = hash
+	t.meta.PlanFile = cfg.PlanFile
+	return saveSessionMeta(t.meta)
+}
+
+// loadSessionSummary reads the summary written by a previous run of a session
+func loadSessionSummary(sessionID string) (*SessionSummary, error) {
+	data, err := os.ReadFile(filepath.Join(sessionDir(sessionID), "summary.json"))
+	if err != nil {
+		return nil, err
+	}
+	var summary SessionSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// iterations returns the number of iterations the session has run so far
+func (t *sessionTracker) iterations() int {
+	return t.meta.Iterations
+}
+
+// recordIteration adds an iteration's outcome to the summary (an errored iteration has
+// Failed set, with whatever result it got) and saves the session metadata
+func (t *sessionTracker) recordIteration(outcome *iterationOutcome) error {
+	t.meta.Iterations++
+	t.record(outcome)
+	t.meta.CostUSD = t.summary.CostUSD
+	t.meta.CostEstimated = t.summary.CostEstimated
+
+	record := IterationRecord{
+		Iteration: t.meta.Iterations,
+		EndedAt:   time.Now(),
+		Status:    iterationStatus(outcome),
+	}
+	if outcome != nil {
+		record.LogFile = outcome.LogPath
+		record.Commit = outcome.Commit
+		record.StartHead = outcome.StartHead
+		record.Rejected = outcome.Rejected
+		if result := outcome.Result; result != nil {
+			record.Agent = result.Agent
+			record.Model = result.Model
+			record.DurationMs = result.DurationMs
+			record.InputTokens = result.Usage.InputTokens
+			record.OutputTokens = result.Usage.OutputTokens
+			record.CostUSD = result.TotalCostUSD
+		}
+	}
+	record.Head, _ = getHeadCommit(t.meta.Worktree)
+
+	if err := saveSessionMeta(t.meta); err != nil {
+		return err
+	}
+	return appendIterationRecord(t.meta.SessionID, record)
+}
+
+// iterationStatus classifies an iteration's outcome for its record
+func iterationStatus(outcome *iterationOutcome) string {
+	switch {
+	case outcome == nil || outcome.Failed:
+		return "failed"
+	case outcome.TimedOut:
+		return "timeout"
+	case outcome.Result != nil && outcome.Result.IsError:
+		return "error"
+	case outcome.VerifyFailed:
+		return "verify_failed"
+	case outcome.Empty && !outcome.Completed:
+		return "empty"
+	default:
+		return "ok"
+	}
+}
+
+// record adds an iteration's outcome to the summary
+func (t *sessionTracker) record(outcome *iterationOutcome) {
+	s := &t.summary
+	s.Iterations++
+
+	if outcome == nil {
+		s.Failed++
+		return
+	}
+
+	if result := outcome.Result; result != nil {
+		s.InputTokens += result.Usage.InputTokens
+		s.OutputTokens += result.Usage.OutputTokens
+		if result.HasCost {
+			s.CostUSD += result.TotalCostUSD
+			s.CostEstimated = s.CostEstimated || result.CostEstimated
+			s.HasCost = true
+		}
+	}
+
+	if outcome.VerifyRan {
+		s.VerificationRuns++
+		if !outcome.VerifyFailed {
+			s.VerificationPassed++
+		}
+	}
+
+	switch iterationStatus(outcome) {
+	case "ok":
+		s.Succeeded++
+	case "verify_failed":
+		s.Skipped++
+	case "empty":
+		s.Empty++
+	default:
+		s.Failed++
+	}
+}
+
+// finish completes the summary with git and plan statistics, writes it to
+// .ralph/sessions/<id>/summary.json and records the exit reason in the metadata
+func (t *sessionTracker) finish(exitReason string) (*SessionSummary, error) {
+	s := &t.summary
+	s.EndedAt = time.Now()
+	s.WallTimeMs = t.priorWallMs + s.EndedAt.Sub(t.runStart).Milliseconds()
+	s.ExitReason = exitReason
+
+	if t.meta.StartHead != "" {
+		s.Commits, _ = countCommitsSince(t.meta.Worktree, t.meta.StartHead)
+		s.FilesChanged, _ = countFilesChangedSince(t.meta.Worktree, t.meta.StartHead)
+	}
+	s.TasksCompleted, s.TasksRemaining = countPlanTasks(filepath.Join(t.meta.Worktree, t.meta.PlanFile))
+
+	t.meta.Status = exitReason
+	t.meta.EndedAt = &s.EndedAt
+	if err := saveSessionMeta(t.meta); err != nil {
+		return s, err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return s, fmt.Errorf("failed to marshal session summary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(sessionDir(s.SessionID), "summary.json"), data, 0644); err != nil {
+		return s, fmt.Errorf("failed to write session summary: %w", err)
+	}
+	return s, nil
+}
+
+// countPlanTasks counts completed (- [x]) and remaining (- [ ]) tasks in a plan file
+func countPlanTasks(planFile string) (completed, remaining int) {
+	f, err := os.Open(planFile)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "- [x]"), strings.HasPrefix(line, "- [X]"):
+			completed++
+		case strings.HasPrefix(line, "- [ ]"):
+			remaining++
+		}
+	}
+	return completed, remaining
+}
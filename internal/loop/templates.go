@@ -0,0 +1,341 @@
+package loop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TemplatesDir is the directory for prompt block overrides, laid out as
+// .ralph/templates/<mode>/<block>.md
+const TemplatesDir = ".ralph/templates"
+
+// TemplateData is the data prompt blocks are rendered with as Go text/templates
+type TemplateData struct {
+	Iteration         int    // Current iteration (1-based)
+	MaxIterations     int    // Iteration limit (0 = unlimited)
+	IterationLabel    string // Iteration for display, e.g. "3/10" or "3/unlimited"
+	NoPush            bool   // Changes are not pushed after each iteration
+	AutoCommit        bool   // goralph commits the agent's changes, so the agent must not commit
+	PlanFile          string // Session plan file path (ralph mode)
+	CompletionPromise string // Line the agent emits when all work is done
+	SessionID         string // Session identifier
+	StateDir          string // RLM state directory (rlm mode)
+	Phase             Phase  // Current RLM phase (rlm mode)
+	PhaseName         string // Display name of the current phase (rlm mode)
+	Depth             int    // Current RLM recursion depth (rlm mode)
+	MaxDepth          int    // Maximum RLM recursion depth (rlm mode)
+	VerifyFixup       bool   // The last verification failed and its changes were kept to be fixed
+	VerifyReverted    bool   // Changes that fail verification are reverted rather than kept
+	// FailedVerification is the last verification report if it failed, holding only
+	// the failed checks with their output tail-truncated (nil otherwise)
+	FailedVerification *VerificationReport
+}
+
+// newTemplateData collects the prompt block data shared by both modes
+func newTemplateData(cfg Config, iteration int) TemplateData {
+	label := fmt.Sprintf("%d/unlimited", iteration)
+	if cfg.MaxIterations > 0 {
+		label = fmt.Sprintf("%d/%d", iteration, cfg.MaxIterations)
+	}
+	return TemplateData{
+		Iteration:         iteration,
+		MaxIterations:     cfg.MaxIterations,
+		IterationLabel:    label,
+		NoPush:            cfg.NoPush,
+		AutoCommit:        cfg.AutoCommit,
+		PlanFile:          cfg.PlanFile,
+		CompletionPromise: CompletionPromise,
+		SessionID:         cfg.SessionID,
+		StateDir:          StateDir,
+		VerifyReverted:    cfg.OnVerifyFail == VerifyFailRevert,
+	}
+}
+
+// previousVerification returns report if it verified the iteration before iteration,
+// or nil if it's a stale report from an older one
+func previousVerification(report *VerificationReport, iteration int) *VerificationReport {
+	if report == nil || report.Iteration != iteration-1 {
+		return nil
+	}
+	return report
+}
+
+// needsFixup reports whether the next iteration must fix changes that failed
+// verification, under the fixup policy
+func needsFixup(cfg Config, lastVerification *VerificationReport) bool {
+	return cfg.OnVerifyFail == VerifyFailFixup && lastVerification != nil && !lastVerification.Passed
+}
+
+// failedVerification returns the failed checks of the last verification report for the
+// prompt, each check's output cut to its last cfg.VerifyOutputLimit bytes, or nil
+// if the last verification passed or none has run
+func failedVerification(cfg Config, lastVerification *VerificationReport) *VerificationReport {
+	if lastVerification == nil || lastVerification.Passed {
+		return nil
+	}
+	report := *lastVerification
+	report.Checks = nil
+	for _, check := range lastVerification.Checks {
+		if check.Passed {
+			continue
+		}
+		check.Output = tailTruncate(strings.TrimSpace(check.Output), cfg.VerifyOutputLimit)
+		report.Checks = append(report.Checks, check)
+	}
+	return &report
+}
+
+// defaultTemplates holds the built-in prompt blocks for each mode
+var defaultTemplates = map[Mode]map[string]string{
+	ModeRalph: {
+		"system-context":    ralphSystemContext,
+		"plan-instructions": ralphPlanInstructions,
+		"verify-fixup":      verifyFixupInstructions,
+		"verify-failed":     verifyFailedReport,
+	},
+	ModeRLM: {
+		"system-context": rlmSystemContext,
+		"phase-plan":     planPhaseGuidance,
+		"phase-search":   searchPhaseGuidance,
+		"phase-narrow":   narrowPhaseGuidance,
+		"phase-act":      actPhaseGuidance,
+		"phase-verify":   verifyPhaseGuidance,
+		"state-files":    stateFileInstructions,
+		"markers":        rlmMarkerInstructions,
+		"verify-fixup":   verifyFixupInstructions,
+		"verify-failed":  verifyFailedReport,
+	},
+}
+
+// TemplateBlocks returns the sorted block names of a mode
+func TemplateBlocks(mode Mode) []string {
+	blocks := make([]string, 0, len(defaultTemplates[mode]))
+	for block := range defaultTemplates[mode] {
+		blocks = append(blocks, block)
+	}
+	sort.Strings(blocks)
+	return blocks
+}
+
+// templatePath returns the override path of a prompt block
+func templatePath(mode Mode, block string) string {
+	return filepath.Join(TemplatesDir, string(mode), block+".md")
+}
+
+// renderBlock renders a prompt block from its override in .ralph/templates/<mode>/<block>.md,
+// falling back to the built-in default. Trailing newlines are trimmed so the caller
+// controls the spacing between blocks.
+func renderBlock(mode Mode, block string, data TemplateData) (string, error) {
+	text, ok := defaultTemplates[mode][block]
+	if !ok {
+		return "", fmt.Errorf("unknown %s prompt block: %s", mode, block)
+	}
+	path := templatePath(mode, block)
+	if override, err := os.ReadFile(path); err == nil {
+		text = string(override)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", path, err)
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// ExportTemplates writes the built-in prompt blocks of every mode to
+// .ralph/templates/<mode>/<block>.md for editing. Existing files are only
+// overwritten with force. It returns the paths written.
+func ExportTemplates(force bool) ([]string, error) {
+	modes := []Mode{ModeRalph, ModeRLM}
+
+	if !force {
+		var existing []string
+		for _, mode := range modes {
+			for _, block := range TemplateBlocks(mode) {
+				if _, err := os.Stat(templatePath(mode, block)); err == nil {
+					existing = append(existing, templatePath(mode, block))
+				}
+			}
+		}
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("refusing to overwrite %s (use --force to overwrite)", strings.Join(existing, ", "))
+		}
+	}
+
+	var written []string
+	for _, mode := range modes {
+		if err := os.MkdirAll(filepath.Join(TemplatesDir, string(mode)), 0755); err != nil {
+			return written, fmt.Errorf("failed to create templates directory: %w", err)
+		}
+		for _, block := range TemplateBlocks(mode) {
+			path := templatePath(mode, block)
+			content := strings.TrimRight(defaultTemplates[mode][block], "\n") + "\n"
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return written, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			written = append(written, path)
+		}
+	}
+	return written, nil
+}
+
+// ralphSystemContext explains the loop to the agent at the top of every ralph prompt
+const ralphSystemContext = `# System Context
+
+You are running in a **goralph agentic loop** - an automated iteration system that manages your context between runs.
+
+**Key facts:**
+- Iteration: {{.IterationLabel}}
+- Each iteration runs with a fresh context window
+- Focus on completing ONE task per iteration
+{{- if .AutoCommit}}
+- After completing a task: update the implementation plan, then exit without committing
+- The loop will automatically commit your changes{{if not .NoPush}}, push them{{end}} and restart
+{{- else if .NoPush}}
+- After completing a task: update the implementation plan, then exit
+- The loop will automatically restart
+{{- else}}
+- After completing a task: update the implementation plan, commit changes, then exit
+- The loop will automatically restart and push your changes
+{{- end}}
+
+**Workflow:**
+1. Study the implementation plan below
+2. Pick the most important uncompleted task
+3. Complete that single task
+4. Update the implementation plan to mark it complete
+{{- if or .NoPush .AutoCommit}}
+5. Exit - the loop handles the rest
+{{- else}}
+5. Commit with a descriptive message
+6. Exit - the loop handles the rest
+{{- end}}
+`
+
+// ralphPlanInstructions tells the agent how to work through the implementation plan
+const ralphPlanInstructions = `# Implementation Plan Instructions
+
+Study the implementation plan below. Pick the most important uncompleted task.
+
+{{if gt .MaxIterations 0 -}}
+If the Tasks section is empty, analyze the project and break the work into approximately {{.MaxIterations}} tasks (one per iteration).
+{{- else -}}
+If the Tasks section is empty, analyze the project and add a comprehensive list of implementation tasks.
+{{- end}}
+
+Complete ONE task, then:
+1. Update ` + "`{{.PlanFile}}`" + ` to mark the task as completed (move to Completed section)
+{{- if or .NoPush .AutoCommit}}
+2. Exit
+{{- else}}
+2. Commit your changes with a descriptive message
+3. Exit
+{{- end}}
+{{- if .AutoCommit}}
+
+Do not commit: the loop stages and commits your changes after you exit, using the completed task as the commit message.
+{{- end}}
+
+**Completion Promise:**
+When ALL tasks in the plan are complete and there is no more work to do, output this exact line:
+` + "`{{.CompletionPromise}}`" + `
+This signals the loop to exit gracefully instead of continuing to the next iteration.
+
+The loop will automatically restart with a fresh context window.
+`
+
+// verifyFixupInstructions tells the agent to fix the kept changes of an iteration
+// that failed verification before moving on
+const verifyFixupInstructions = `# Fix the Previous Iteration
+
+The previous iteration's changes failed verification and were kept. Before picking a new task, fix them so that verification passes.
+`
+
+// verifyFailedReport shows the agent the failed checks of the last verification
+const verifyFailedReport = `# Previous Verification Failed
+{{with .FailedVerification}}
+Verification of iteration {{.Iteration}} failed.
+{{- if $.VerifyReverted}} Its changes were reverted; make sure your next attempt doesn't fail the same way.
+{{- else if $.VerifyFixup}} Fix these failures before starting a new task.
+{{- else}} Its changes were kept.
+{{- end}}
+{{range .Checks}}
+## ` + "`{{.Command}}`" + `
+{{if .Error}}
+Error: {{.Error}}
+{{end}}
+{{- if .Output}}
+` + "```" + `
+{{.Output}}
+` + "```" + `
+{{end}}
+{{- end}}
+{{- end}}
+`
+
+// rlmSystemContext explains the RLM principles and session state at the top of every RLM prompt
+const rlmSystemContext = `# System Context
+
+You are running in a **goralph RLM-enhanced agentic loop**.
+
+## RLM Principles
+
+1. **Context is external**: The full codebase is NOT in your context. Use tools to explore.
+2. **State persists**: Discoveries are stored in {{.StateDir}}. Reference previous findings.
+{{if or .NoPush .AutoCommit -}}
+3. **One task per iteration**: Complete ONE task, implement changes, update state, exit.
+{{- else -}}
+3. **One task per iteration**: Complete ONE task, update state, commit, exit.
+{{- end}}
+4. **Verify before commit**: Run relevant checks before marking complete.
+{{- if .AutoCommit}}
+5. **Don't commit**: The loop stages and commits your changes after you exit.
+{{- end}}
+
+## Session Info
+
+- **Iteration:** {{.IterationLabel}}
+- **Session ID:** {{.SessionID}}
+- **Current Phase:** {{.Phase}} ({{.PhaseName}})
+- **Depth:** {{.Depth}}/{{.MaxDepth}}
+
+## Available State Files
+
+- Context manifest: {{.StateDir}}/context.json
+- Previous searches: {{.StateDir}}/search/
+- Narrowed sets: {{.StateDir}}/narrow/
+- History: {{.StateDir}}/history.jsonl
+- Verification reports: {{.StateDir}}/verification/
+`
+
+// rlmMarkerInstructions describes the output markers the RLM loop parses
+const rlmMarkerInstructions = `## RLM Output Markers
+
+Use these markers to communicate state transitions:
+
+1. **Phase transition:** Signal which phase to enter next:
+   ` + "`<rlm:phase>PHASE_NAME</rlm:phase>`" + `
+   Valid phases: PLAN, SEARCH, NARROW, ACT, VERIFY
+
+2. **Verification passed:** Signal that verification succeeded:
+   ` + "`<rlm:verified>true</rlm:verified>`" + `
+
+3. **Session complete:** Signal all tasks are done:
+   ` + "`<promise>COMPLETE</promise>`" + `
+{{- if not (or .NoPush .AutoCommit)}}
+
+**Important:** Always commit your changes before signaling completion.
+{{- end}}
+`
@@ -19,16 +19,70 @@ const (
 
 // Config holds the loop configuration
 type Config struct {
-	PromptFile     string
-	PlanFile       string // Session-scoped plan file path
-	MaxIterations  int
-	NoPush         bool
-	Agent          AgentProvider
-	Output         io.Writer
-	Mode           Mode   // Execution mode (ralph or rlm)
-	RLMMaxDepth    int    // Maximum recursion depth for RLM mode
-	VerifyEnabled  bool   // Run verification before commit
-	VerifyCommands []string // Custom verification commands (auto-detected if empty)
+	PromptFile        string
+	PlanFile          string // Session-scoped plan file path
+	SessionID         string // Session identifier (generated by Run if empty)
+	Resume            string // Session ID to resume, or "latest" (empty starts a new session)
+	Force             bool   // Resume even if the prompt file changed since the session started
+	ScratchDir        string // When set, plan and RLM state files are kept here instead of .ralph/ (dry runs)
+	MaxIterations     int
+	NoPush            bool
+	AutoCommit        bool     // goralph stages and commits after each iteration instead of the agent
+	Branch            string   // Branch to work on: "" (checked out branch), "auto" (ralph/<session-id>) or a name
+	ProtectedBranches []string // Branch name patterns the loop refuses to run on
+	AllowProtected    bool     // Run even on a protected branch
+	Worktree          bool     // Run the agent in a dedicated git worktree under .ralph/worktrees/
+	WorktreeCleanup   string   // What to do with the worktree when the session exits: keep, remove or on-complete
+	WorkDir           string   // Directory the agent runs in and git operates on ("" = current directory)
+	OnVerifyFail      string   // What to do with a failed iteration's changes: "keep" (default), "revert" or "fixup"
+	Agent             AgentProvider
+	FallbackAgents    []AgentProvider // Agents tried in order when the primary agent fails
+	Model             string          // Model passed to the agent CLI (empty for the agent's default)
+	Output            io.Writer
+	Mode              Mode                    // Execution mode (ralph or rlm)
+	RLMMaxDepth       int                     // Maximum recursion depth for RLM mode
+	VerifyEnabled     bool                    // Run verification before commit
+	VerifyCommands    []string                // Custom verification commands (auto-detected if empty)
+	VerifyOutputLimit int                     // Bytes kept from the end of each failed check's output in the next prompt (0 = all)
+	Profile           string                  // Name of the profile the config was resolved with (empty if none)
+	Command           CommandConfig           // Settings for the generic command provider (agent: command)
+	Retry             RetryPolicy             // Retry policy for failed iterations and pushes
+	IterationTimeout  time.Duration           // Wall-clock limit per agent run (0 = none)
+	IdleTimeout       time.Duration           // Kill the agent if it produces no output for this long (0 = none)
+	OnTimeout         string                  // What to do after a timeout: "continue" (default) or "stop"
+	MaxCost           float64                 // Session cost limit in USD (0 = unlimited)
+	MaxTokens         int                     // Session input+output token limit (0 = unlimited)
+	BudgetWarnPercent int                     // Warn once usage reaches this percentage of a limit (0 = never)
+	Pricing           map[string]ModelPricing // Per-model rates for estimating cost when the agent doesn't report it
+	Vars              map[string]string       // Template variables available to the prompt file
+	Tasks             bool                    // Work through the prompt files in .ralph/tasks/ in order
+	TaskMaxIterations int                     // Iterations per task before moving to the next (0 = unlimited)
+}
+
+// Timeout policies
+const (
+	// TimeoutContinue skips the timed out iteration's push and starts the next iteration
+	TimeoutContinue = "continue"
+	// TimeoutStop ends the session with an error
+	TimeoutStop = "stop"
+)
+
+// Verification failure policies
+const (
+	// VerifyFailKeep keeps the failed iteration's changes and skips the push
+	VerifyFailKeep = "keep"
+	// VerifyFailRevert resets to the pre-iteration commit, saving the changes as a patch
+	VerifyFailRevert = "revert"
+	// VerifyFailFixup keeps the changes and tells the next iteration to fix them
+	VerifyFailFixup = "fixup"
+)
+
+// CommandConfig configures the generic command provider for arbitrary agent CLIs
+type CommandConfig struct {
+	Name   string   `yaml:"name"`   // Display name (defaults to the executable name)
+	Args   []string `yaml:"args"`   // Argv to run; {prompt_file} is replaced with the prompt path in file mode
+	Input  string   `yaml:"input"`  // How the prompt is passed: "stdin" (default) or "file"
+	Format string   `yaml:"format"` // Output format: "text" (default), "claude", "codex" or "gemini"
 }
 
 // GeneratePlanPath returns a timestamped path for a new session-scoped plan file.
@@ -42,8 +96,10 @@ func GeneratePlanPath() string {
 type AgentProvider string
 
 const (
-	AgentClaude AgentProvider = "claude"
-	AgentCodex  AgentProvider = "codex"
+	AgentClaude  AgentProvider = "claude"
+	AgentCodex   AgentProvider = "codex"
+	AgentGemini  AgentProvider = "gemini"
+	AgentCommand AgentProvider = "command" // Generic provider configured via Config.Command
 )
 
 // ValidateAgentProvider checks if the given agent provider is valid
@@ -53,8 +109,12 @@ func ValidateAgentProvider(agent string) (AgentProvider, error) {
 		return AgentClaude, nil
 	case AgentCodex:
 		return AgentCodex, nil
+	case AgentGemini:
+		return AgentGemini, nil
+	case AgentCommand:
+		return AgentCommand, nil
 	default:
-		return "", fmt.Errorf("unknown agent provider: %q (valid options: claude, codex)", agent)
+		return "", fmt.Errorf("unknown agent provider: %q (valid options: claude, codex, gemini, command)", agent)
 	}
 }
 
@@ -74,18 +134,25 @@ type SystemMessage struct {
 
 // ResultMessage represents the final result message from Claude
 type ResultMessage struct {
-	Type            string  `json:"type"`
-	Subtype         string  `json:"subtype"`
-	IsError         bool    `json:"is_error"`
-	DurationMs      int     `json:"duration_ms"`
-	NumTurns        int     `json:"num_turns"`
-	Result          string  `json:"result"`
-	TotalCostUSD    float64 `json:"total_cost_usd"`
-	Usage           Usage   `json:"usage"`
-	HasCost         bool    `json:"-"` // Internal field: true if provider supplies cost data
-	SessionComplete bool    `json:"-"` // Internal: true if agent emitted completion promise
-	ModePhase       string  `json:"-"` // Internal: detected phase from mode-specific markers
-	ModeVerified    bool    `json:"-"` // Internal: true if mode signaled verified
+	Type            string   `json:"type"`
+	Subtype         string   `json:"subtype"`
+	IsError         bool     `json:"is_error"`
+	DurationMs      int      `json:"duration_ms"`
+	NumTurns        int      `json:"num_turns"`
+	Result          string   `json:"result"`
+	TotalCostUSD    float64  `json:"total_cost_usd"`
+	Usage           Usage    `json:"usage"`
+	Model           string   `json:"-"` // Internal: model reported by (or configured for) the agent
+	Agent           string   `json:"-"` // Internal: name of the provider that produced this result
+	AgentError      string   `json:"-"` // Internal: rate-limit/overload/error result that triggers provider fallback
+	Fallbacks       []string `json:"-"` // Internal: provider switches made during the iteration
+	TimedOut        bool     `json:"-"` // Internal: agent was killed by the iteration or idle timeout
+	TimeoutReason   string   `json:"-"` // Internal: which timeout fired and its limit
+	HasCost         bool     `json:"-"` // Internal field: true if provider supplies cost data
+	CostEstimated   bool     `json:"-"` // Internal: cost was estimated from the pricing table
+	SessionComplete bool     `json:"-"` // Internal: true if agent emitted completion promise
+	ModePhase       string   `json:"-"` // Internal: detected phase from mode-specific markers
+	ModeVerified    bool     `json:"-"` // Internal: true if mode signaled verified
 }
 
 // Usage represents token usage statistics
@@ -198,6 +265,64 @@ type CodexItem struct {
 	Name    string `json:"name,omitempty"` // For MCP tool calls
 }
 
+// GeminiEvent represents a generic event from Gemini CLI stream-json output
+type GeminiEvent struct {
+	Type string `json:"type"`
+}
+
+// GeminiInitEvent represents the init event from Gemini CLI
+type GeminiInitEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	Model     string `json:"model"`
+}
+
+// GeminiMessageEvent represents a message event (user or assistant content) from Gemini CLI
+type GeminiMessageEvent struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Delta   bool   `json:"delta,omitempty"`
+}
+
+// GeminiToolUseEvent represents a tool_use event from Gemini CLI
+type GeminiToolUseEvent struct {
+	Type     string `json:"type"`
+	ToolName string `json:"tool_name"`
+	ToolID   string `json:"tool_id"`
+}
+
+// GeminiToolResultEvent represents a tool_result event from Gemini CLI
+type GeminiToolResultEvent struct {
+	Type   string `json:"type"`
+	ToolID string `json:"tool_id"`
+	Status string `json:"status"`
+}
+
+// GeminiErrorEvent represents an error event from Gemini CLI
+type GeminiErrorEvent struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+}
+
+// GeminiResultEvent represents the final result event from Gemini CLI
+type GeminiResultEvent struct {
+	Type   string      `json:"type"`
+	Status string      `json:"status"`
+	Stats  GeminiStats `json:"stats"`
+}
+
+// GeminiStats represents session statistics from Gemini CLI
+type GeminiStats struct {
+	TotalTokens  int `json:"total_tokens"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	Cached       int `json:"cached"`
+	DurationMs   int `json:"duration_ms"`
+	ToolCalls    int `json:"tool_calls"`
+}
+
 // VerificationReport contains the results of verification checks
 type VerificationReport struct {
 	Iteration int                 `json:"iteration"`
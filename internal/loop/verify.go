@@ -2,16 +2,19 @@ package loop
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Verifier runs verification commands before commit
 type Verifier struct {
 	commands []string
+	Dir      string // Directory the commands run in ("" = current directory)
 }
 
 // NewVerifier creates a new Verifier with the specified commands
@@ -23,8 +26,9 @@ func NewVerifier(commands []string) *Verifier {
 	return &Verifier{commands: commands}
 }
 
-// Run executes all verification commands and returns a report
-func (v *Verifier) Run(iteration int) VerificationReport {
+// Run executes all verification commands and returns a report. The commands run in
+// their own process group, so only a second Ctrl-C (canceling ctx) stops them.
+func (v *Verifier) Run(ctx context.Context, iteration int) VerificationReport {
 	report := VerificationReport{
 		Iteration: iteration,
 		Passed:    true,
@@ -33,7 +37,7 @@ func (v *Verifier) Run(iteration int) VerificationReport {
 	}
 
 	for _, cmd := range v.commands {
-		check := v.runCheck(cmd)
+		check := v.runCheck(ctx, cmd)
 		report.Checks = append(report.Checks, check)
 		if !check.Passed {
 			report.Passed = false
@@ -44,7 +48,7 @@ func (v *Verifier) Run(iteration int) VerificationReport {
 }
 
 // runCheck executes a single verification command
-func (v *Verifier) runCheck(cmdStr string) VerificationCheck {
+func (v *Verifier) runCheck(ctx context.Context, cmdStr string) VerificationCheck {
 	check := VerificationCheck{
 		Name:    cmdStr,
 		Command: cmdStr,
@@ -57,7 +61,11 @@ func (v *Verifier) runCheck(cmdStr string) VerificationCheck {
 		return check
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = v.Dir
+	setProcessGroup(ctx, cmd)
+	// Don't hang on pipes held open by orphaned children once the command is gone
+	cmd.WaitDelay = killGracePeriod
 
 	// Capture output
 	var stdout, stderr bytes.Buffer
@@ -83,6 +91,24 @@ func (v *Verifier) runCheck(cmdStr string) VerificationCheck {
 	return check
 }
 
+// tailTruncate keeps the last limit bytes of output, starting at a line boundary
+// where possible, and notes how much was cut. A limit of 0 keeps everything.
+func tailTruncate(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+	tail := output[len(output)-limit:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	} else {
+		// No line boundary; don't start in the middle of a UTF-8 sequence
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+	}
+	return fmt.Sprintf("... (%d bytes truncated)\n%s", len(output)-len(tail), tail)
+}
+
 // HasCommands returns true if the verifier has commands to run
 func (v *Verifier) HasCommands() bool {
 	return len(v.commands) > 0
@@ -0,0 +1,103 @@
+package loop
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorktreesDir is the directory holding the git worktree of each worktree session
+const WorktreesDir = ".ralph/worktrees"
+
+// Worktree cleanup policies
+const (
+	// WorktreeKeep leaves the worktree in place when the session exits
+	WorktreeKeep = "keep"
+	// WorktreeRemove removes the worktree when the session exits
+	WorktreeRemove = "remove"
+	// WorktreeRemoveOnComplete removes the worktree only when the session completes
+	WorktreeRemoveOnComplete = "on-complete"
+)
+
+// ValidateWorktreeCleanup checks that a worktree cleanup policy is known
+func ValidateWorktreeCleanup(policy string) error {
+	switch policy {
+	case WorktreeKeep, WorktreeRemove, WorktreeRemoveOnComplete:
+		return nil
+	default:
+		return fmt.Errorf("unknown worktree_cleanup policy: %q (valid options: %s, %s, %s)", policy, WorktreeKeep, WorktreeRemove, WorktreeRemoveOnComplete)
+	}
+}
+
+// worktreePath returns the worktree directory of a session
+func worktreePath(sessionID string) string {
+	return filepath.Join(WorktreesDir, sessionID)
+}
+
+// setupWorktree creates a worktree at path with branch checked out, creating the branch
+// from HEAD if it doesn't exist. An existing worktree (a resumed session) is reused.
+// It reports whether the worktree was created.
+func setupWorktree(path, branch string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		current, err := getCurrentBranch(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read branch of worktree %s: %w", path, err)
+		}
+		if current != branch {
+			return false, fmt.Errorf("worktree %s has %s checked out, not %s", path, current, branch)
+		}
+		return false, nil
+	}
+
+	args := []string{"worktree", "add", path, branch}
+	if !branchExists(branch) {
+		args = []string{"worktree", "add", "-b", branch, path}
+	}
+	var stderr bytes.Buffer
+	cmd := gitCommand("", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("failed to create worktree %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return true, nil
+}
+
+// removeWorktree removes a worktree, keeping its branch. goralph's own files (plans and
+// local state) are discarded with it, but a worktree holding any other uncommitted
+// change is left in place, so no agent work is lost.
+func removeWorktree(path string) error {
+	pathspecs := append(projectPathspecs(path), ":(exclude)"+PlansDir)
+	status, err := getStatusPorcelain(path, pathspecs...)
+	if err != nil {
+		return fmt.Errorf("failed to check worktree %s for uncommitted changes: %w", path, err)
+	}
+	if status != "" {
+		return fmt.Errorf("worktree %s has uncommitted changes, not removing it", path)
+	}
+
+	var stderr bytes.Buffer
+	cmd := gitCommand("", "worktree", "remove", "--force", path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to remove worktree %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// cleanupWorktree applies the worktree cleanup policy once the session exits
+func cleanupWorktree(w io.Writer, cfg Config, exitReason string) {
+	remove := cfg.WorktreeCleanup == WorktreeRemove ||
+		(cfg.WorktreeCleanup == WorktreeRemoveOnComplete && exitReason == ExitComplete)
+	if !remove {
+		fmt.Fprintln(w, dimStyle.Render("Worktree kept at "+cfg.WorkDir))
+		return
+	}
+	if err := removeWorktree(cfg.WorkDir); err != nil {
+		fmt.Fprintln(w, dimStyle.Render(fmt.Sprintf("Warning: %v", err)))
+		return
+	}
+	fmt.Fprintln(w, dimStyle.Render("Removed worktree "+cfg.WorkDir))
+}
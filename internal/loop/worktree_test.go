@@ -0,0 +1,66 @@
+package loop
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runWorktreeIteration runs one iteration of a ralph session in a new worktree,
+// with an agent running script, and returns the session's config
+func runWorktreeIteration(t *testing.T, script string) Config {
+	t.Helper()
+	initTestProject(t)
+
+	cfg := DefaultConfig()
+	cfg.SessionID = "s1"
+	cfg.PlanFile = PlansDir + "/implementation_plan_s1.md"
+	cfg.NoPush = true
+	cfg.Output = io.Discard
+	cfg.Worktree = true
+	cfg.WorktreeCleanup = WorktreeRemove
+	cfg.WorkDir = worktreePath(cfg.SessionID)
+	if _, err := setupWorktree(cfg.WorkDir, "ralph/s1"); err != nil {
+		t.Fatalf("setupWorktree: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.WorkDir, PlansDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRalphRunner()
+	runner.SetOutput(io.Discard)
+	if err := runner.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	agent := newTestCommandProvider(t, "agent", CommandFormatText, script)
+	if _, err := runIteration(context.Background(), cfg, []Provider{agent}, 1, runner, nil); err != nil {
+		t.Fatalf("runIteration: %v", err)
+	}
+	return cfg
+}
+
+func TestCleanupWorktreeRemovesAfterIteration(t *testing.T) {
+	// The agent commits its work, leaving only goralph's untracked plan behind
+	cfg := runWorktreeIteration(t, `echo work > work.txt && git add work.txt && git commit -q -m "Add work"`)
+
+	cleanupWorktree(io.Discard, cfg, ExitMaxIterations)
+
+	if _, err := os.Stat(cfg.WorkDir); !os.IsNotExist(err) {
+		t.Fatalf("worktree %s still exists (err %v)", cfg.WorkDir, err)
+	}
+	if subject := testGit(t, "log", "-1", "--format=%s", "ralph/s1"); subject != "Add work" {
+		t.Errorf("branch head = %q, want the agent's commit", subject)
+	}
+}
+
+func TestCleanupWorktreeKeepsUncommittedWork(t *testing.T) {
+	cfg := runWorktreeIteration(t, "echo work > work.txt")
+
+	cleanupWorktree(io.Discard, cfg, ExitMaxIterations)
+
+	if _, err := os.Stat(filepath.Join(cfg.WorkDir, "work.txt")); err != nil {
+		t.Fatalf("expected the agent's uncommitted work to be kept: %v", err)
+	}
+}